@@ -14,9 +14,17 @@ import (
 	"github.com/bookstore/services/catalog/internal/config"
 	"github.com/bookstore/services/catalog/internal/db"
 	"github.com/bookstore/services/catalog/internal/events"
+	"github.com/bookstore/services/catalog/internal/fx"
+	"github.com/bookstore/services/catalog/internal/gateway"
 	grpcserver "github.com/bookstore/services/catalog/internal/grpc"
+	"github.com/bookstore/services/catalog/internal/health"
 	"github.com/bookstore/services/catalog/internal/repo"
+	"github.com/bookstore/services/catalog/internal/saga"
+	wstransport "github.com/bookstore/services/catalog/internal/transport/websocket"
 	"github.com/bookstore/services/catalog/pkg/logger"
+	"github.com/bookstore/services/catalog/pkg/observability"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
@@ -28,14 +36,63 @@ func main() {
 	cfg := config.Load()
 
 	// Initialize logger
-	log := logger.NewLogger(cfg.ServiceName, cfg.LogLevel)
+	log, logLevel := logger.NewAtomicLogger(cfg.ServiceName, cfg.LogLevel)
 	defer log.Sync()
 
 	log.Info("Catalog service starting")
 
+	// Initialize OpenTelemetry tracing; every gRPC, GORM, and AMQP call
+	// made from here on is instrumented without further plumbing.
+	shutdownTracing, err := observability.Init(cfg.ServiceName)
+	if err != nil {
+		log.Warn("Failed to initialize OpenTelemetry, continuing without tracing", zap.Error(err))
+		shutdownTracing = nil
+	}
+	if shutdownTracing != nil {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Error("Failed to shut down tracing", zap.Error(err))
+			}
+		}()
+	}
+
+	// Start the health monitor and the HTTP server that exposes it before
+	// doing anything else that can block or fail (DB connect, migrations,
+	// broker connect): /startupz reports 503 until MarkStarted is called
+	// below, so a k8s startup probe can poll it from the moment the pod's
+	// port opens instead of the port not existing until migrations finish.
+	monitor := health.NewMonitor(cfg.HealthFailureThreshold)
+	httpMux := http.NewServeMux()
+	httpMux.HandleFunc("/livez", livezHandler())
+	httpMux.HandleFunc("/readyz", readyHandler(monitor, log))
+	httpMux.HandleFunc("/startupz", startupHandler(monitor))
+	httpMux.Handle("/metrics", promhttp.Handler())
+	httpMux.Handle("/admin/loglevel", requireAdminToken(cfg.AdminToken, logLevel))
+
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%s", cfg.HTTPHealthPort),
+		Handler:      httpMux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	go func() {
+		log.Info("Starting HTTP server", zap.String("address", httpServer.Addr))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Failed to serve HTTP", zap.Error(err))
+		}
+	}()
+
 	// Connect to database
 	log.Info("Connecting to database...")
-	database, err := db.Connect(cfg.PGDSN)
+	database, err := db.Connect(db.Config{
+		Driver:      cfg.DBDriver,
+		PrimaryDSN:  cfg.PGDSN,
+		ReplicaDSNs: cfg.DBReplicaDSNs,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database", zap.Error(err))
 	}
@@ -46,8 +103,23 @@ func main() {
 		log.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
+	// Export DB pool stats (sqlDB.Stats()) to /metrics for each pool member.
+	statsCtx, stopStatsCollectors := context.WithCancel(context.Background())
+	defer stopStatsCollectors()
+	for _, member := range database.Members() {
+		go observability.RunDBStatsCollector(statsCtx, member.Name, member.Stats, 15*time.Second)
+	}
+
+	// Seed the FX provider with a static snapshot and start it refreshing
+	// on a timer; fetchSeedRates is a placeholder for a real exchange-rate
+	// API until one is wired in.
+	fxProvider := fx.NewInMemoryProvider(seedFXRates)
+	fxCtx, stopFXRefresher := context.WithCancel(context.Background())
+	defer stopFXRefresher()
+	go fx.RunRefresher(fxCtx, fxProvider, fetchSeedRates, cfg.FXRefreshInterval, log)
+
 	// Initialize repository
-	catalogRepo := repo.NewCatalogRepository(database, log)
+	catalogRepo := repo.NewCatalogRepository(database, log, fxProvider)
 
 	// Connect to RabbitMQ
 	log.Info("Connecting to RabbitMQ")
@@ -57,6 +129,23 @@ func main() {
 	}
 	defer publisher.Close()
 
+	// Fan every event Publisher sends out to in-process WebSocket
+	// subscribers too, so clients get live updates without polling
+	// gRPC ListBooks or standing up their own RabbitMQ consumer.
+	eventHub := events.NewEventHub(log)
+	publisher.SetHub(eventHub)
+	httpMux.Handle("/ws/events", wstransport.NewHandler(eventHub, cfg.WSToken, log))
+
+	// Start the outbox dispatcher: CreateBook/UpdateBook enqueue their
+	// events in the outbox table in the same transaction as the book
+	// mutation, and this drains it into the broker, so an event is never
+	// lost even if RabbitMQ is unreachable or the process crashes right
+	// after commit.
+	outboxDispatcher := events.NewOutboxDispatcher(catalogRepo, publisher, log)
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	go outboxDispatcher.Start(dispatcherCtx)
+
 	// Connect to Inventory service
 	log.Info("Connecting to Inventory service")
 	inventoryClient, err := clients.NewInventoryClient(log)
@@ -68,17 +157,56 @@ func main() {
 		defer inventoryClient.Close()
 	}
 
+	// Start the saga orchestrator: register the order_fulfillment saga
+	// (reserve stock, publish order.confirmed, releasing the reservation
+	// if publishing fails) and resume any instance left in-flight by a
+	// previous crash.
+	if inventoryClient != nil {
+		sagaRepo := repo.NewSagaRepository(database, log)
+		sagaCoordinator := saga.NewCoordinator(sagaRepo, log)
+		sagaCoordinator.Register(saga.NewOrderFulfillmentDef(inventoryClient, publisher))
+		if err := sagaCoordinator.Recover(context.Background()); err != nil {
+			log.Warn("Failed to recover in-flight sagas", zap.Error(err))
+		}
+	}
+
 	// Create gRPC server
 	grpcServer := grpc.NewServer(
-		grpc.UnaryInterceptor(grpcserver.LoggingInterceptor(log)),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			observability.UnaryServerInterceptor(),
+			grpcserver.LoggingInterceptor(log),
+		),
 	)
 
 	// Register catalog service
-	catalogService := grpcserver.NewCatalogServer(catalogRepo, publisher, inventoryClient, log)
+	catalogService := grpcserver.NewCatalogServer(catalogRepo, publisher, inventoryClient, fxProvider, log)
 	grpcserver.RegisterCatalogService(grpcServer, catalogService)
 
+	// Register dependency checks on the monitor started earlier: it probes
+	// the database and RabbitMQ every few seconds and caches the result
+	// for Check/Watch and /readyz, instead of each of them pinging inline.
+	for _, member := range database.Members() {
+		monitor.RegisterCheck(member.Name, member.Ping)
+	}
+	monitor.RegisterCheck("broker", func() error {
+		if !publisher.IsHealthy() {
+			return fmt.Errorf("rabbitmq connection unhealthy")
+		}
+		return nil
+	})
+
+	// Probe once synchronously so /startupz has a real result to report
+	// before we flip it to started, then keep refreshing on a timer.
+	monitor.Probe()
+	monitor.MarkStarted()
+
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go monitor.Run(monitorCtx, 5*time.Second)
+
 	// Register health service
-	healthServer := grpcserver.NewHealthServer(database, publisher, log)
+	healthServer := grpcserver.NewHealthServer(monitor, log)
 	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
 
 	// Enable reflection for grpcurl/grpcui
@@ -97,24 +225,16 @@ func main() {
 		}
 	}()
 
-	// Start HTTP server for health check
-	httpMux := http.NewServeMux()
-	httpMux.HandleFunc("/healthz", healthHandler(database, publisher, log))
-
-	httpServer := &http.Server{
-		Addr:         fmt.Sprintf(":%s", cfg.HTTPHealthPort),
-		Handler:      httpMux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  120 * time.Second,
+	// Mount the REST/JSON gateway and its OpenAPI spec on the httpMux
+	// already serving /livez, /readyz, and /startupz; ServeMux registration
+	// is safe to do while the server is live, so existing gRPC and HTTP
+	// callers are unaffected by mounting these routes this late.
+	httpMux.HandleFunc("/openapi.json", gateway.ServeOpenAPI)
+	gatewayMux, err := gateway.NewMux(context.Background(), catalogService, log)
+	if err != nil {
+		log.Fatal("Failed to initialize REST gateway", zap.Error(err))
 	}
-
-	go func() {
-		log.Info("Starting HTTP server", zap.String("address", httpServer.Addr))
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Failed to serve HTTP", zap.Error(err))
-		}
-	}()
+	httpMux.Handle("/v1/", gatewayMux)
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
@@ -138,25 +258,78 @@ func main() {
 	log.Info("Server stopped")
 }
 
-func healthHandler(database *db.DB, publisher *events.Publisher, log *zap.Logger) http.HandlerFunc {
+// seedFXRates are the exchange rates (units per 1 fx.BaseCurrency) used
+// until a real exchange-rate source is integrated.
+var seedFXRates = map[string]float64{
+	"EUR": 0.92,
+	"GBP": 0.79,
+	"JPY": 156.50,
+	"MXN": 18.20,
+}
+
+// fetchSeedRates stands in for a call to a real exchange-rate API; it
+// always returns seedFXRates, so RunRefresher's periodic ticks are
+// harmless no-ops until a real FetchFunc replaces this one.
+func fetchSeedRates(ctx context.Context) (map[string]float64, error) {
+	return seedFXRates, nil
+}
+
+// livezHandler reports process liveness only: it always returns 200, since
+// the process being able to answer HTTP at all is the only thing a
+// liveness probe should check. A Postgres or RabbitMQ hiccup must not
+// fail liveness, or Kubernetes restarts the pod instead of just routing
+// traffic away from it via readiness.
+func livezHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Check database connection
-		if err := database.Ping(); err != nil {
-			log.Error("Database health check failed", zap.Error(err))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("alive"))
+	}
+}
+
+// startupHandler reports 503 until the monitor's first dependency probe
+// has completed, so a k8s startup probe gates traffic/liveness checks
+// until migrations and the initial DB/broker probe have actually run,
+// instead of racing them.
+func startupHandler(monitor *health.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !monitor.Started() {
 			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("unhealthy: database connection failed"))
+			w.Write([]byte("starting"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("started"))
+	}
+}
+
+// requireAdminToken gates next behind the X-Admin-Token header matching
+// token, so the log-level endpoint can't be flipped by anyone who can
+// merely reach the health port. If token is unset (local dev with no
+// ADMIN_TOKEN configured), the check is skipped.
+func requireAdminToken(token string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("X-Admin-Token") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized"))
 			return
 		}
+		next.ServeHTTP(w, r)
+	}
+}
 
-		// Check RabbitMQ connection
-		if !publisher.IsHealthy() {
-			log.Error("RabbitMQ health check failed")
+// readyHandler reports readiness from the same monitor, so it flips to
+// unready as soon as the database or broker degrades rather than always
+// returning OK.
+func readyHandler(monitor *health.Monitor, log *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if status, _ := monitor.Status(""); status == health.StatusNotServing {
+			log.Warn("Readiness check failed")
 			w.WriteHeader(http.StatusServiceUnavailable)
-			w.Write([]byte("unhealthy: rabbitmq connection failed"))
+			w.Write([]byte("not ready"))
 			return
 		}
 
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("healthy"))
+		w.Write([]byte("ready"))
 	}
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	inventorypb "github.com/bookstore/contracts/gen/go/inventory"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -34,6 +35,7 @@ func NewInventoryClient(log *zap.Logger) (*InventoryClient, error) {
 		ctx,
 		inventoryURL,
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
 		grpc.WithBlock(),
 	)
 	if err != nil {
@@ -73,6 +75,43 @@ func (c *InventoryClient) GetStock(ctx context.Context, itemID string) (int32, e
 	return resp.Item.Quantity, nil
 }
 
+// ReservedItem is one line item to reserve or release stock for.
+type ReservedItem struct {
+	ItemID   string
+	Quantity int32
+}
+
+// ReserveStock asks inventory to reserve items for orderID, returning
+// an error if the RPC itself fails and (success=false, nil error) if
+// inventory declined the reservation (e.g. insufficient stock).
+func (c *InventoryClient) ReserveStock(ctx context.Context, orderID string, items []ReservedItem) (bool, string, error) {
+	req := &inventorypb.ReserveStockRequest{OrderId: orderID, Items: toPBItems(items)}
+	resp, err := c.client.ReserveStock(ctx, req)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to reserve stock for order %s: %w", orderID, err)
+	}
+	return resp.Success, resp.Message, nil
+}
+
+// ReleaseStock asks inventory to release a previous reservation for
+// orderID.
+func (c *InventoryClient) ReleaseStock(ctx context.Context, orderID string, items []ReservedItem) (bool, string, error) {
+	req := &inventorypb.ReleaseStockRequest{OrderId: orderID, Items: toPBItems(items)}
+	resp, err := c.client.ReleaseStock(ctx, req)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to release stock for order %s: %w", orderID, err)
+	}
+	return resp.Success, resp.Message, nil
+}
+
+func toPBItems(items []ReservedItem) []*inventorypb.ReservedItem {
+	pbItems := make([]*inventorypb.ReservedItem, 0, len(items))
+	for _, item := range items {
+		pbItems = append(pbItems, &inventorypb.ReservedItem{ItemId: item.ItemID, Quantity: item.Quantity})
+	}
+	return pbItems
+}
+
 // Close closes the connection to inventory service
 func (c *InventoryClient) Close() error {
 	if c.conn != nil {
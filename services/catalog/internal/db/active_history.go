@@ -0,0 +1,20 @@
+package db
+
+import "time"
+
+// ActiveHistory records a single flip of a book's Active flag, so
+// visibility changes made via CatalogRepository.ToggleActive/DeleteBook
+// can be audited after the fact.
+type ActiveHistory struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	SKU        string    `gorm:"type:varchar(50);not null;index:idx_active_history_sku" json:"sku"`
+	FromActive bool      `gorm:"not null" json:"from_active"`
+	ToActive   bool      `gorm:"not null" json:"to_active"`
+	ChangedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"changed_at"`
+	ChangedBy  string    `gorm:"type:varchar(255);not null" json:"changed_by"`
+}
+
+// TableName specifies the table name for ActiveHistory model
+func (ActiveHistory) TableName() string {
+	return "active_history"
+}
@@ -0,0 +1,34 @@
+package db
+
+import "time"
+
+// Category is a node in the book categorization taxonomy. ParentID is
+// nil for a top-level category; a non-nil ParentID points at another
+// Category row, letting categories nest (e.g. "Fiction" -> "Science
+// Fiction") without a fixed depth limit.
+type Category struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Slug      string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"slug"`
+	ParentID  *uint     `gorm:"index" json:"parent_id,omitempty"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName specifies the table name for Category model
+func (Category) TableName() string {
+	return "categories"
+}
+
+// BookCategory links a book to one of its categories. A book may belong
+// to any number of categories, and a category may have any number of
+// books; (BookSKU, CategoryID) is the join table's primary key.
+type BookCategory struct {
+	BookSKU    string `gorm:"primaryKey;type:varchar(50)" json:"book_sku"`
+	CategoryID uint   `gorm:"primaryKey" json:"category_id"`
+}
+
+// TableName specifies the table name for BookCategory model
+func (BookCategory) TableName() string {
+	return "book_categories"
+}
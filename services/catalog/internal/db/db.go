@@ -2,44 +2,159 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
 	"time"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
+	"gorm.io/plugin/opentelemetry/tracing"
 )
 
 // DB wraps the GORM database connection
 type DB struct {
 	*gorm.DB
+	members []PoolMember
 }
 
-// Connect establishes a connection to the PostgreSQL database
-func Connect(dsn string) (*DB, error) {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+// PoolMember names one connection in the primary/replica pool, for
+// independent health probing (see Members).
+type PoolMember struct {
+	Name  string
+	Ping  func() error
+	Stats func() (sql.DBStats, error)
+}
+
+// Config drives Connect: which driver to dial, the primary DSN, and any
+// read-replica DSNs to register with GORM's dbresolver plugin.
+type Config struct {
+	// Driver is "postgres" (default) or "sqlite". sqlite is intended for
+	// local dev and tests that need to run without a Postgres instance.
+	Driver      string
+	PrimaryDSN  string
+	ReplicaDSNs []string
+}
+
+// Connect establishes a connection to the configured database driver and,
+// when ReplicaDSNs is non-empty, routes reads to them via dbresolver
+// while writes (and anything wrapped in WithPrimary) stay on the primary.
+func Connect(cfg Config) (*DB, error) {
+	primaryDialector, err := dialector(cfg.Driver, cfg.PrimaryDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	gdb, err := gorm.Open(primaryDialector, &gorm.Config{
 		Logger:                 logger.Default.LogMode(logger.Info),
 		SkipDefaultTransaction: true,
 		PrepareStmt:            true,
+		TranslateError:         true,
 	})
 	if err != nil {
 		return nil, err
 	}
 
-	// Get underlying SQL DB
-	sqlDB, err := db.DB()
+	members := []PoolMember{{Name: "primary", Ping: sqlPinger(gdb), Stats: sqlStats(gdb)}}
+
+	if len(cfg.ReplicaDSNs) > 0 {
+		replicaDialectors := make([]gorm.Dialector, 0, len(cfg.ReplicaDSNs))
+		for i, dsn := range cfg.ReplicaDSNs {
+			d, err := dialector(cfg.Driver, dsn)
+			if err != nil {
+				return nil, err
+			}
+			replicaDialectors = append(replicaDialectors, d)
+
+			// Open the replica a second time, standalone, purely so its
+			// pool can be health-probed independently of dbresolver's
+			// internal connection for the same DSN.
+			replicaGDB, err := gorm.Open(d, &gorm.Config{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to open replica %d for health probing: %w", i, err)
+			}
+			members = append(members, PoolMember{
+				Name:  fmt.Sprintf("replica-%d", i),
+				Ping:  sqlPinger(replicaGDB),
+				Stats: sqlStats(replicaGDB),
+			})
+		}
+
+		if err := gdb.Use(dbresolver.Register(dbresolver.Config{
+			Replicas: replicaDialectors,
+		})); err != nil {
+			return nil, fmt.Errorf("failed to register read replicas: %w", err)
+		}
+	}
+
+	if err := gdb.Use(tracing.NewPlugin()); err != nil {
+		return nil, fmt.Errorf("failed to register otel tracing plugin: %w", err)
+	}
+
+	sqlDB, err := gdb.DB()
 	if err != nil {
 		return nil, err
 	}
-
-	// Set connection pool settings
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	return &DB{DB: db}, nil
+	return &DB{DB: gdb, members: members}, nil
 }
 
-// Ping checks if the database connection is alive
+func dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", "postgres":
+		return postgres.Open(dsn), nil
+	case "sqlite":
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %q", driver)
+	}
+}
+
+func sqlPinger(gdb *gorm.DB) func() error {
+	return func() error {
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+func sqlStats(gdb *gorm.DB) func() (sql.DBStats, error) {
+	return func() (sql.DBStats, error) {
+		sqlDB, err := gdb.DB()
+		if err != nil {
+			return sql.DBStats{}, err
+		}
+		return sqlDB.Stats(), nil
+	}
+}
+
+// Members returns one PoolMember per connection (the primary, plus any
+// registered replicas), so callers can register an independent health
+// check per pool member.
+func (db *DB) Members() []PoolMember {
+	return db.members
+}
+
+// WithPrimary forces gdb to route through the primary instead of a
+// replica, for read-your-writes right after a mutation. dbresolver has
+// no context-wrapping form of this (Write is a Clauses operation, not a
+// function), so callers apply it to the *gorm.DB itself:
+// db.WithPrimary(r.db.WithContext(ctx)).Find(...). Safe to call even
+// when no replicas are registered.
+func WithPrimary(gdb *gorm.DB) *gorm.DB {
+	return gdb.Clauses(dbresolver.Write)
+}
+
+// Ping checks if the primary database connection is alive
 func (db *DB) Ping() error {
 	sqlDB, err := db.DB.DB()
 	if err != nil {
@@ -60,7 +175,3 @@ func (db *DB) Close() error {
 	}
 	return sqlDB.Close()
 }
-
-
-
-
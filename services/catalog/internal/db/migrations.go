@@ -1,18 +1,51 @@
 package db
 
 import (
+	"context"
+
 	"gorm.io/gorm"
 )
 
-// RunMigrations runs all database migrations
+// RunMigrations runs all database migrations. It always targets the
+// primary via WithPrimary, so it is a no-op against replicas even if
+// dbresolver's read/write routing would otherwise have sent some of its
+// statements elsewhere.
 func RunMigrations(db *DB) error {
+	primary := WithPrimary(db.WithContext(context.Background()))
+
 	// Auto-migrate Book model
-	if err := db.AutoMigrate(&Book{}); err != nil {
+	if err := primary.AutoMigrate(&Book{}); err != nil {
+		return err
+	}
+
+	// Auto-migrate saga orchestrator tables
+	if err := primary.AutoMigrate(&SagaInstance{}, &SagaStepLog{}); err != nil {
+		return err
+	}
+
+	// Auto-migrate the transactional outbox
+	if err := primary.AutoMigrate(&Outbox{}, &OutboxDeadLetter{}); err != nil {
+		return err
+	}
+
+	// Auto-migrate the category taxonomy and its book join table
+	if err := primary.AutoMigrate(&Category{}, &BookCategory{}); err != nil {
+		return err
+	}
+
+	// Auto-migrate the active-state audit trail
+	if err := primary.AutoMigrate(&ActiveHistory{}); err != nil {
 		return err
 	}
 
 	// Create additional indexes if not exists
-	if err := createIndexes(db.DB); err != nil {
+	if err := createIndexes(primary); err != nil {
+		return err
+	}
+
+	// Maintain books.search_vector via trigger instead of building
+	// to_tsvector(...) on every SearchBooks query.
+	if err := createSearchVectorColumn(primary); err != nil {
 		return err
 	}
 
@@ -22,12 +55,6 @@ func RunMigrations(db *DB) error {
 func createIndexes(db *gorm.DB) error {
 	// Full-text search indexes for PostgreSQL
 	indexes := []string{
-		// GIN index for full-text search on title
-		`CREATE INDEX IF NOT EXISTS idx_books_title_search ON books USING gin(to_tsvector('english', title))`,
-
-		// GIN index for full-text search on author
-		`CREATE INDEX IF NOT EXISTS idx_books_author_search ON books USING gin(to_tsvector('english', author))`,
-
 		// Composite index for common queries
 		`CREATE INDEX IF NOT EXISTS idx_books_active_category ON books(active, category) WHERE active = true`,
 	}
@@ -41,6 +68,51 @@ func createIndexes(db *gorm.DB) error {
 	return nil
 }
 
+// createSearchVectorColumn adds a stored search_vector column to books,
+// kept in sync by a trigger from title/author/description/category (with
+// A/B/C/D weights respectively) instead of computing to_tsvector(...) on
+// every SearchBooks query. It supersedes the old idx_books_title_search
+// and idx_books_author_search indexes, which this migration drops.
+func createSearchVectorColumn(db *gorm.DB) error {
+	statements := []string{
+		`ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector`,
 
+		`CREATE OR REPLACE FUNCTION books_search_vector_update() RETURNS trigger AS $$
+		BEGIN
+			NEW.search_vector :=
+				setweight(to_tsvector('english', coalesce(NEW.title, '')), 'A') ||
+				setweight(to_tsvector('english', coalesce(NEW.author, '')), 'B') ||
+				setweight(to_tsvector('english', coalesce(NEW.description, '')), 'C') ||
+				setweight(to_tsvector('english', coalesce(NEW.category, '')), 'D');
+			RETURN NEW;
+		END;
+		$$ LANGUAGE plpgsql`,
 
+		`DROP TRIGGER IF EXISTS books_search_vector_trigger ON books`,
 
+		`CREATE TRIGGER books_search_vector_trigger
+			BEFORE INSERT OR UPDATE ON books
+			FOR EACH ROW EXECUTE FUNCTION books_search_vector_update()`,
+
+		// Backfill rows written before the trigger existed.
+		`UPDATE books SET search_vector =
+			setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(author, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(description, '')), 'C') ||
+			setweight(to_tsvector('english', coalesce(category, '')), 'D')
+			WHERE search_vector IS NULL`,
+
+		`CREATE INDEX IF NOT EXISTS idx_books_search_vector ON books USING gin(search_vector)`,
+
+		`DROP INDEX IF EXISTS idx_books_title_search`,
+		`DROP INDEX IF EXISTS idx_books_author_search`,
+	}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -17,8 +17,14 @@ type Book struct {
 	Description string    `gorm:"type:text" json:"description,omitempty"`
 	CreatedAt   time.Time `gorm:"not null;default:CURRENT_TIMESTAMP;index:idx_books_created_at" json:"created_at"`
 	UpdatedAt   time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
-	Active      bool      `gorm:"not null;default:true;index:idx_books_active" json:"active"`
-	Stock       *int32    `gorm:"default:0" json:"stock,omitempty"` // Optional, synced from Inventory service
+	// No gorm "default:true" tag: GORM substitutes a field's default-value
+	// tag for its INSERT column whenever the field holds its zero value,
+	// which for bool means every explicitly-false Active (e.g. a book
+	// created inactive) would silently be written as true. Callers always
+	// set Active explicitly (see CreateBook), so the DB-level default
+	// serves no purpose and only introduces that bug.
+	Active bool   `gorm:"not null;index:idx_books_active" json:"active"`
+	Stock  *int32 `gorm:"default:0" json:"stock,omitempty"` // Optional, synced from Inventory service
 }
 
 // TableName specifies the table name for Book model
@@ -43,7 +49,3 @@ func (b *Book) BeforeUpdate(tx *gorm.DB) error {
 	b.UpdatedAt = time.Now()
 	return nil
 }
-
-
-
-
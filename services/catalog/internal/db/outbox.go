@@ -0,0 +1,46 @@
+package db
+
+import "time"
+
+// Outbox is a durable, transactionally-written record of a domain event
+// raised by a repository mutation (e.g. CreateBook). Writing the event
+// row in the same transaction as the mutation it describes, instead of
+// publishing to RabbitMQ inline, means the event is never lost even if
+// the broker is unreachable or the process crashes right after commit;
+// OutboxDispatcher delivers it separately.
+type Outbox struct {
+	ID          string     `gorm:"primaryKey;type:uuid" json:"id"`
+	AggregateID string     `gorm:"type:varchar(255);not null" json:"aggregate_id"`
+	EventType   string     `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload     []byte     `gorm:"type:jsonb;not null" json:"payload"`
+	CreatedAt   time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP;index:idx_outbox_unpublished" json:"created_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	Attempts    int32      `gorm:"not null;default:0" json:"attempts"`
+	// NextAttemptAt gates retries behind an exponential backoff computed
+	// from Attempts (see MarkOutboxFailed); nil means the row has never
+	// failed and is claimable as soon as it exists.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// TableName specifies the table name for Outbox model
+func (Outbox) TableName() string {
+	return "outbox"
+}
+
+// OutboxDeadLetter is a copy of an Outbox row that exhausted its retry
+// budget, kept for manual inspection after OutboxDispatcher removes the
+// row from the active outbox.
+type OutboxDeadLetter struct {
+	ID             string    `gorm:"primaryKey;type:uuid" json:"id"`
+	OutboxID       string    `gorm:"type:uuid;not null" json:"outbox_id"`
+	AggregateID    string    `gorm:"type:varchar(255);not null" json:"aggregate_id"`
+	EventType      string    `gorm:"type:varchar(100);not null" json:"event_type"`
+	Payload        []byte    `gorm:"type:jsonb;not null" json:"payload"`
+	Attempts       int32     `gorm:"not null" json:"attempts"`
+	DeadLetteredAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"dead_lettered_at"`
+}
+
+// TableName specifies the table name for OutboxDeadLetter model
+func (OutboxDeadLetter) TableName() string {
+	return "outbox_dead_letters"
+}
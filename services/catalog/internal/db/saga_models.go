@@ -0,0 +1,40 @@
+package db
+
+import "time"
+
+// SagaInstance is a durable row tracking one execution of a saga.Def
+// orchestrated by internal/saga.Coordinator. State and CurrentStep are
+// updated after every step transition so a crash mid-saga can be
+// resumed from exactly where it left off.
+type SagaInstance struct {
+	ID          string    `gorm:"primaryKey;type:varchar(64)" json:"id"`
+	Name        string    `gorm:"type:varchar(100);not null;index:idx_saga_instances_name" json:"name"`
+	State       string    `gorm:"type:varchar(20);not null;index:idx_saga_instances_state" json:"state"`
+	CurrentStep string    `gorm:"type:varchar(100)" json:"current_step"`
+	Payload     []byte    `gorm:"type:jsonb" json:"payload"`
+	UpdatedAt   time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName specifies the table name for SagaInstance model
+func (SagaInstance) TableName() string {
+	return "saga_instances"
+}
+
+// SagaStepLog is an append-only audit trail of every attempt a
+// Coordinator made at a step, including compensations. It backs both
+// idempotency checks (has this step already succeeded?) and the
+// reverse walk the Coordinator does to compensate a failed saga.
+type SagaStepLog struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	SagaID    string    `gorm:"type:varchar(64);not null;index:idx_saga_step_log_saga" json:"saga_id"`
+	Step      string    `gorm:"type:varchar(100);not null" json:"step"`
+	Status    string    `gorm:"type:varchar(20);not null" json:"status"`
+	Attempt   int       `gorm:"not null;default:1" json:"attempt"`
+	Error     string    `gorm:"type:text" json:"error,omitempty"`
+	CreatedAt time.Time `gorm:"not null;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TableName specifies the table name for SagaStepLog model
+func (SagaStepLog) TableName() string {
+	return "saga_step_log"
+}
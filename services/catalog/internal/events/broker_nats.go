@@ -0,0 +1,55 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsBroker publishes CloudEvents onto a NATS JetStream subject, with the
+// AMQP routing key reused verbatim as the subject name.
+type natsBroker struct {
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+func newNATSBroker(url string) (*natsBroker, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
+	}
+
+	return &natsBroker{conn: conn, js: js}, nil
+}
+
+func (b *natsBroker) Publish(ctx context.Context, topic string, event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cloud event: %w", err)
+	}
+
+	if _, err := b.js.Publish(topic, body, nats.Context(ctx)); err != nil {
+		return fmt.Errorf("failed to publish to NATS JetStream: %w", err)
+	}
+
+	return nil
+}
+
+func (b *natsBroker) IsHealthy() bool {
+	return b.conn != nil && b.conn.IsConnected()
+}
+
+func (b *natsBroker) Close() error {
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	return nil
+}
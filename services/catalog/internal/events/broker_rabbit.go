@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+const (
+	rabbitExchangeName = "bookstore.events"
+	rabbitExchangeType = "topic"
+
+	// Retry configuration
+	maxRetries     = 3
+	initialBackoff = 100 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// rabbitBroker publishes CloudEvents to a RabbitMQ topic exchange with
+// publisher confirms and exponential-backoff retry.
+type rabbitBroker struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	log     *zap.Logger
+}
+
+func newRabbitBroker(url string, log *zap.Logger) (*rabbitBroker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := channel.ExchangeDeclare(
+		rabbitExchangeName,
+		rabbitExchangeType,
+		true,  // durable
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,   // arguments
+	); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	// Enable publisher confirms for reliability
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+	}
+
+	log.Info("Connected to RabbitMQ", zap.String("exchange", rabbitExchangeName))
+
+	return &rabbitBroker{conn: conn, channel: channel, log: log}, nil
+}
+
+func (b *rabbitBroker) Publish(ctx context.Context, topic string, event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		b.log.Error("Failed to marshal event", zap.Error(err))
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	headers := amqp.Table{
+		"specversion": event.SpecVersion,
+		"type":        event.Type,
+	}
+	otel.GetTextMapPropagator().Inject(ctx, amqpHeaderCarrier(headers))
+
+	backoff := initialBackoff
+	var lastErr error
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		if attempt > 0 {
+			publishMetrics.retries.Inc()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+			}
+		}
+
+		publishMetrics.attempts.Inc()
+
+		// Publish with confirmation
+		confirms := b.channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+		err := b.channel.PublishWithContext(
+			ctx,
+			rabbitExchangeName,
+			topic,
+			false, // mandatory
+			false, // immediate
+			amqp.Publishing{
+				ContentType:  "application/cloudevents+json",
+				DeliveryMode: amqp.Persistent,
+				Timestamp:    time.Now(),
+				MessageId:    event.ID,
+				Body:         body,
+				Headers:      headers,
+			},
+		)
+
+		if err != nil {
+			lastErr = err
+			b.log.Warn("Failed to publish event, retrying",
+				zap.Int("attempt", attempt+1),
+				zap.Error(err),
+			)
+			continue
+		}
+
+		// Wait for confirmation
+		select {
+		case confirm := <-confirms:
+			if confirm.Ack {
+				publishMetrics.confirms.Inc()
+				b.log.Info("Event published successfully",
+					zap.String("event_id", event.ID),
+					zap.String("event_type", event.Type),
+					zap.String("routing_key", topic),
+				)
+				return nil
+			}
+			lastErr = fmt.Errorf("event not acknowledged")
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			lastErr = fmt.Errorf("confirmation timeout")
+		}
+
+		b.log.Warn("Event publish not confirmed, retrying",
+			zap.Int("attempt", attempt+1),
+			zap.Error(lastErr),
+		)
+	}
+
+	publishMetrics.failures.Inc()
+	b.log.Error("Failed to publish event after retries",
+		zap.String("event_id", event.ID),
+		zap.String("event_type", event.Type),
+		zap.Int("attempts", maxRetries),
+		zap.Error(lastErr),
+	)
+	return fmt.Errorf("failed to publish event after %d attempts: %w", maxRetries, lastErr)
+}
+
+func (b *rabbitBroker) IsHealthy() bool {
+	return b.conn != nil && !b.conn.IsClosed()
+}
+
+func (b *rabbitBroker) Close() error {
+	if b.channel != nil {
+		if err := b.channel.Close(); err != nil {
+			b.log.Error("Failed to close channel", zap.Error(err))
+		}
+	}
+	if b.conn != nil {
+		if err := b.conn.Close(); err != nil {
+			b.log.Error("Failed to close connection", zap.Error(err))
+			return err
+		}
+	}
+	b.log.Info("Broker closed")
+	return nil
+}
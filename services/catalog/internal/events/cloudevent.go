@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CloudEventsSpecVersion is the CloudEvents spec version implemented here.
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEvent is the CloudEvents 1.0 JSON envelope every event published by
+// this service is wrapped in, regardless of which Broker delivers it.
+//
+// See https://github.com/cloudevents/spec/blob/v1.0/spec.md#required-attributes
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Subject         string          `json:"subject,omitempty"`
+	CorrelationID   string          `json:"correlationid,omitempty"` // CloudEvents extension attribute
+	Data            json.RawMessage `json:"data"`
+}
+
+// correlationIDKeyType is the context key used to stash a request's
+// correlation id so it rides along on any CloudEvent published downstream.
+type correlationIDKeyType struct{}
+
+var correlationIDKey = correlationIDKeyType{}
+
+// WithCorrelationID returns a context carrying correlationID for later
+// retrieval by NewCloudEvent.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, correlationID)
+}
+
+// NewCloudEvent wraps payload in a CloudEvents 1.0 envelope. source
+// identifies the emitting service (e.g. "bookstore/catalog") and subject is
+// typically the aggregate id the event is about (a book SKU).
+func NewCloudEvent(ctx context.Context, source, eventType, subject string, payload interface{}) (CloudEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	event := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          source,
+		Type:            eventType,
+		Time:            time.Now().UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Subject:         subject,
+		Data:            data,
+	}
+
+	if corrID, ok := ctx.Value(correlationIDKey).(string); ok {
+		event.CorrelationID = corrID
+	} else if corrID, ok := ctx.Value("correlation_id").(string); ok {
+		// Back-compat with the ad-hoc key callers already set on the context.
+		event.CorrelationID = corrID
+	}
+
+	return event, nil
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// hubSubscriberBuffer bounds how many undelivered events a slow WebSocket
+// client can accumulate before EventHub drops it, so one stuck consumer
+// can't grow memory unbounded or block delivery to everyone else.
+const hubSubscriberBuffer = 64
+
+// Event is a single domain event fanned out to in-process subscribers,
+// mirroring what Publisher sends to the broker: a routing key, the
+// aggregate id it's about, and the raw JSON payload.
+type Event struct {
+	Topic   string          `json:"topic"`
+	Subject string          `json:"subject"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// HubFilter narrows a Subscribe call to the events a caller cares about.
+// The zero value matches everything. Topics, if set, restricts delivery
+// to exactly those routing keys; Subject, if set, restricts to a single
+// aggregate id (e.g. one book SKU).
+type HubFilter struct {
+	Topics  []string
+	Subject string
+}
+
+func (f HubFilter) matches(e Event) bool {
+	if f.Subject != "" && f.Subject != e.Subject {
+		return false
+	}
+	if len(f.Topics) == 0 {
+		return true
+	}
+	for _, topic := range f.Topics {
+		if topic == e.Topic {
+			return true
+		}
+	}
+	return false
+}
+
+type hubSubscriber struct {
+	filter HubFilter
+	ch     chan Event
+}
+
+// EventHub fans out the same events Publisher sends to the broker to any
+// number of in-process subscribers, so a WebSocket handler can stream
+// live updates to clients without round-tripping them through RabbitMQ.
+type EventHub struct {
+	log *zap.Logger
+
+	mu          sync.Mutex
+	subscribers map[int]*hubSubscriber
+	nextID      int
+}
+
+// NewEventHub returns a hub that Publisher.publish dispatches into
+// alongside broker; see Publisher.SetHub.
+func NewEventHub(log *zap.Logger) *EventHub {
+	return &EventHub{
+		log:         log,
+		subscribers: make(map[int]*hubSubscriber),
+	}
+}
+
+// Subscribe registers a new subscriber matching filter and returns its
+// event channel plus an unsubscribe function the caller must invoke
+// exactly once (typically via defer) when it stops reading.
+func (h *EventHub) Subscribe(filter HubFilter) (<-chan Event, func()) {
+	h.mu.Lock()
+	h.nextID++
+	id := h.nextID
+	sub := &hubSubscriber{filter: filter, ch: make(chan Event, hubSubscriberBuffer)}
+	h.subscribers[id] = sub
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, id)
+			h.mu.Unlock()
+		})
+	}
+	return sub.ch, unsubscribe
+}
+
+// Dispatch fans payload out, as topic/subject, to every subscriber whose
+// filter matches. A subscriber whose buffer is full is dropped instead of
+// blocking delivery to the rest, since one slow WebSocket client
+// shouldn't be able to stall event delivery for everyone else.
+func (h *EventHub) Dispatch(topic, subject string, payload json.RawMessage) {
+	event := Event{Topic: topic, Subject: subject, Payload: payload}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, sub := range h.subscribers {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			h.log.Warn("Dropping slow WebSocket subscriber", zap.Int("subscriber_id", id))
+			close(sub.ch)
+			delete(h.subscribers, id)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/bookstore/services/catalog/internal/repo"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 50
+	outboxMaxAttempts  = 5
+)
+
+// OutboxDispatcher drains the outbox table into the configured
+// Publisher, so catalog mutations get at-least-once event delivery even
+// across process restarts or broker outages, instead of publishing
+// inline with the request that raised the event. Rows are claimed and
+// attempted in per-aggregate order, but a row that fails does not hold
+// back later rows for the same aggregate: those are still published,
+// and the failed row is retried once its backoff has passed.
+type OutboxDispatcher struct {
+	repo      *repo.CatalogRepository
+	publisher *Publisher
+	log       *zap.Logger
+}
+
+// NewOutboxDispatcher returns a dispatcher that drains repository's
+// outbox table through publisher.
+func NewOutboxDispatcher(repository *repo.CatalogRepository, publisher *Publisher, log *zap.Logger) *OutboxDispatcher {
+	return &OutboxDispatcher{repo: repository, publisher: publisher, log: log}
+}
+
+// Start polls the outbox every outboxPollInterval until ctx is cancelled.
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.drain(ctx); err != nil {
+				d.log.Error("Outbox drain failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+// drain claims one batch of outbox rows and attempts to publish each in
+// order. A row that keeps failing past outboxMaxAttempts is moved to
+// the dead-letter table instead of being retried forever.
+//
+// The claim and every row's mark run inside one transaction (see
+// repo.CatalogRepository.Transaction) so the SKIP LOCKED lock taken by
+// ClaimOutboxBatchTx is held for the whole batch instead of being
+// released the instant the claim query returns: without that, the lock
+// protected nothing, since a second dispatcher replica could claim the
+// same rows before this one got around to publishing and marking them.
+func (d *OutboxDispatcher) drain(ctx context.Context) error {
+	if lag, err := d.repo.OldestUnpublishedOutboxAge(ctx); err == nil {
+		outboxMetricsInstance.lagSeconds.Set(lag.Seconds())
+	}
+
+	return d.repo.Transaction(ctx, func(tx *gorm.DB) error {
+		batch, err := d.repo.ClaimOutboxBatchTx(tx, outboxBatchSize, outboxMaxAttempts)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			if err := d.publisher.PublishRaw(ctx, row.EventType, row.AggregateID, row.Payload); err != nil {
+				d.log.Warn("Failed to publish outbox row",
+					zap.String("id", row.ID), zap.String("event_type", row.EventType), zap.Error(err))
+				outboxMetricsInstance.publishFailures.Inc()
+
+				if row.Attempts+1 >= outboxMaxAttempts {
+					if err := d.repo.DeadLetterOutboxTx(tx, row); err != nil {
+						d.log.Error("Failed to dead-letter outbox row", zap.String("id", row.ID), zap.Error(err))
+						continue
+					}
+					outboxMetricsInstance.deadLettered.Inc()
+					continue
+				}
+
+				if err := d.repo.MarkOutboxFailedTx(tx, row.ID, row.Attempts); err != nil {
+					d.log.Error("Failed to mark outbox row failed", zap.String("id", row.ID), zap.Error(err))
+				}
+				continue
+			}
+
+			if err := d.repo.MarkOutboxPublishedTx(tx, row.ID); err != nil {
+				d.log.Error("Failed to mark outbox row published", zap.String("id", row.ID), zap.Error(err))
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,34 @@
+package events
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// outboxMetrics exposes the outbox dispatcher's health as Prometheus
+// gauges/counters.
+type outboxMetrics struct {
+	lagSeconds      prometheus.Gauge
+	deadLettered    prometheus.Counter
+	publishFailures prometheus.Counter
+}
+
+func newOutboxMetrics() *outboxMetrics {
+	m := &outboxMetrics{
+		lagSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "catalog_outbox_lag_seconds",
+			Help: "Age of the oldest unpublished outbox row, in seconds.",
+		}),
+		deadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "catalog_outbox_dead_lettered_total",
+			Help: "Outbox rows moved to outbox_dead_letters after exhausting their retry budget.",
+		}),
+		publishFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "catalog_outbox_publish_failures_total",
+			Help: "Failed attempts to publish an outbox row, including ones later retried successfully.",
+		}),
+	}
+	prometheus.MustRegister(m.lagSeconds, m.deadLettered, m.publishFailures)
+	return m
+}
+
+// outboxMetricsInstance is the package-wide outbox registry, shared by
+// every OutboxDispatcher.
+var outboxMetricsInstance = newOutboxMetrics()
@@ -0,0 +1,38 @@
+package events
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// publishMetricsT tracks every attempt/confirm/retry made while
+// publishing, so /metrics reflects broker reliability the way the zap
+// logs already do.
+type publishMetricsT struct {
+	attempts prometheus.Counter
+	confirms prometheus.Counter
+	retries  prometheus.Counter
+	failures prometheus.Counter
+}
+
+var publishMetrics = newPublishMetrics()
+
+func newPublishMetrics() *publishMetricsT {
+	m := &publishMetricsT{
+		attempts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "event_publish_attempts_total",
+			Help: "Total publish attempts made to the broker, including retries.",
+		}),
+		confirms: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "event_publish_confirms_total",
+			Help: "Total publishes acknowledged by the broker.",
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "event_publish_retries_total",
+			Help: "Total publish retries after a failed attempt or unconfirmed publish.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "event_publish_failures_total",
+			Help: "Total publishes that exhausted all retries without being confirmed.",
+		}),
+	}
+	prometheus.MustRegister(m.attempts, m.confirms, m.retries, m.failures)
+	return m
+}
@@ -3,268 +3,162 @@ package events
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"time"
 
-	"github.com/google/uuid"
-	amqp "github.com/rabbitmq/amqp091-go"
 	"go.uber.org/zap"
 )
 
+// Event type routing keys, used both as the AMQP/NATS/Pub/Sub topic and,
+// once wrapped in a CloudEvent, to derive the "type" attribute (see
+// cloudEventType).
 const (
-	exchangeName = "bookstore.events"
-	exchangeType = "topic"
-
-	// Event types
 	EventTypeCatalogCreated = "catalog.created"
 	EventTypeCatalogUpdated = "catalog.updated"
 	EventTypeCatalogDeleted = "catalog.deleted"
 
-	// Retry configuration
-	maxRetries     = 3
-	initialBackoff = 100 * time.Millisecond
-	maxBackoff     = 5 * time.Second
+	// EventTypeCategoryAttached/EventTypeCategoryDetached are published
+	// (via OutboxDispatcher's PublishRaw) when repo.CatalogRepository's
+	// AttachCategories/DetachCategories link or unlink a book and a
+	// category.
+	EventTypeCategoryAttached = "category.attached"
+	EventTypeCategoryDetached = "category.detached"
+
+	// EventTypeOrderConfirmed is published by the saga orchestrator
+	// (internal/saga) once an order's stock reservation has been
+	// committed, so downstream consumers know the order is final.
+	EventTypeOrderConfirmed = "order.confirmed"
+
+	// EventTypeBookActivated/EventTypeBookDeactivated are published (via
+	// OutboxDispatcher's PublishRaw) when repo.CatalogRepository's
+	// ToggleActive/DeleteBook flips a book's Active flag, instead of the
+	// generic EventTypeCatalogUpdated, so subscribers that only care about
+	// visibility changes don't have to inspect fields_changed.
+	EventTypeBookActivated   = "book.activated"
+	EventTypeBookDeactivated = "book.deactivated"
 )
 
-// Publisher handles event publishing to RabbitMQ
-type Publisher struct {
-	conn    *amqp.Connection
-	channel *amqp.Channel
-	log     *zap.Logger
+// cloudEventSource identifies this service as the CloudEvents "source".
+const cloudEventSource = "bookstore/catalog"
+
+// cloudEventType builds the CloudEvents "type" attribute from a routing
+// key, e.g. "catalog.created" -> "com.bookstore.catalog.created.v1".
+func cloudEventType(routingKey string) string {
+	return "com.bookstore." + routingKey + ".v1"
 }
 
-// Event represents a domain event
-type Event struct {
-	EventID       string                 `json:"event_id"`
-	EventType     string                 `json:"event_type"`
-	EventVersion  string                 `json:"event_version"`
-	Timestamp     string                 `json:"timestamp"`
-	CorrelationID string                 `json:"correlation_id,omitempty"`
-	Payload       map[string]interface{} `json:"payload"`
+// EventPublisher is the subset of Publisher's API that CatalogServer
+// depends on, so tests can inject a stub instead of a real broker
+// connection.
+type EventPublisher interface {
+	PublishBookCreated(ctx context.Context, sku, title, author, category, currency string, price int64, active bool) error
+	PublishBookUpdated(ctx context.Context, sku string, fieldsChanged []string, updates map[string]interface{}) error
+	PublishBookDeleted(ctx context.Context, sku string) error
+	IsHealthy() bool
+	Close() error
 }
 
-// NewPublisher creates a new event publisher
-func NewPublisher(url string, log *zap.Logger) (*Publisher, error) {
-	conn, err := amqp.Dial(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
+// Publisher publishes domain events as CloudEvents through a pluggable
+// Broker (RabbitMQ, NATS JetStream, or Google Pub/Sub).
+type Publisher struct {
+	broker Broker
+	log    *zap.Logger
+	hub    *EventHub
+}
 
-	channel, err := conn.Channel()
+// NewPublisher opens a Broker for brokerURL (see OpenBroker for accepted
+// schemes) and returns a Publisher bound to it.
+func NewPublisher(brokerURL string, log *zap.Logger) (*Publisher, error) {
+	broker, err := OpenBroker(brokerURL, log)
 	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
+		return nil, err
 	}
 
-	// Declare exchange
-	if err := channel.ExchangeDeclare(
-		exchangeName,
-		exchangeType,
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	); err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
-	}
+	return &Publisher{broker: broker, log: log}, nil
+}
 
-	// Enable publisher confirms for reliability
-	if err := channel.Confirm(false); err != nil {
-		channel.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+// SetHub wires hub into p, so every event published from here on is also
+// fanned out to hub's in-process subscribers (the WebSocket transport),
+// in addition to being sent to the broker. Safe to leave unset: publish
+// skips fan-out entirely when hub is nil.
+func (p *Publisher) SetHub(hub *EventHub) {
+	p.hub = hub
+}
+
+// publish wraps payload in a CloudEvent and publishes it on routingKey,
+// preserving the pre-CloudEvents routing keys so existing queue bindings
+// keep working. It also fans the same payload out through p.hub, if one
+// is set, so WebSocket subscribers see it without waiting on the broker.
+func (p *Publisher) publish(ctx context.Context, routingKey, subject string, payload interface{}) error {
+	event, err := NewCloudEvent(ctx, cloudEventSource, cloudEventType(routingKey), subject, payload)
+	if err != nil {
+		p.log.Error("Failed to build cloud event", zap.Error(err))
+		return err
 	}
 
-	log.Info("Connected to RabbitMQ", zap.String("exchange", exchangeName))
+	if p.hub != nil {
+		p.hub.Dispatch(routingKey, subject, event.Data)
+	}
 
-	return &Publisher{
-		conn:    conn,
-		channel: channel,
-		log:     log,
-	}, nil
+	return p.broker.Publish(ctx, routingKey, event)
 }
 
 // PublishBookCreated publishes a book created event
 func (p *Publisher) PublishBookCreated(ctx context.Context, sku, title, author, category, currency string, price int64, active bool) error {
-	event := Event{
-		EventID:      uuid.New().String(),
-		EventType:    EventTypeCatalogCreated,
-		EventVersion: "1.0.0",
-		Timestamp:    time.Now().UTC().Format(time.RFC3339),
-		Payload: map[string]interface{}{
-			"sku":      sku,
-			"title":    title,
-			"author":   author,
-			"price":    price,
-			"currency": currency,
-			"category": category,
-			"active":   active,
-		},
-	}
-
-	// Extract correlation ID from context if available
-	if corrID := ctx.Value("correlation_id"); corrID != nil {
-		event.CorrelationID = corrID.(string)
-	}
-
-	return p.publishWithRetry(ctx, EventTypeCatalogCreated, event)
+	return p.publish(ctx, EventTypeCatalogCreated, sku, map[string]interface{}{
+		"sku":      sku,
+		"title":    title,
+		"author":   author,
+		"price":    price,
+		"currency": currency,
+		"category": category,
+		"active":   active,
+	})
 }
 
 // PublishBookUpdated publishes a book updated event
 func (p *Publisher) PublishBookUpdated(ctx context.Context, sku string, fieldsChanged []string, updates map[string]interface{}) error {
-	event := Event{
-		EventID:      uuid.New().String(),
-		EventType:    EventTypeCatalogUpdated,
-		EventVersion: "1.0.0",
-		Timestamp:    time.Now().UTC().Format(time.RFC3339),
-		Payload: map[string]interface{}{
-			"sku":            sku,
-			"fields_changed": fieldsChanged,
-		},
+	payload := map[string]interface{}{
+		"sku":            sku,
+		"fields_changed": fieldsChanged,
 	}
-
-	// Add updated field values to payload
 	for k, v := range updates {
-		event.Payload[k] = v
+		payload[k] = v
 	}
 
-	// Extract correlation ID from context if available
-	if corrID := ctx.Value("correlation_id"); corrID != nil {
-		event.CorrelationID = corrID.(string)
-	}
-
-	return p.publishWithRetry(ctx, EventTypeCatalogUpdated, event)
+	return p.publish(ctx, EventTypeCatalogUpdated, sku, payload)
 }
 
 // PublishBookDeleted publishes a book deleted event
 func (p *Publisher) PublishBookDeleted(ctx context.Context, sku string) error {
-	event := Event{
-		EventID:      uuid.New().String(),
-		EventType:    EventTypeCatalogDeleted,
-		EventVersion: "1.0.0",
-		Timestamp:    time.Now().UTC().Format(time.RFC3339),
-		Payload: map[string]interface{}{
-			"sku": sku,
-		},
-	}
-
-	// Extract correlation ID from context if available
-	if corrID := ctx.Value("correlation_id"); corrID != nil {
-		event.CorrelationID = corrID.(string)
-	}
-
-	return p.publishWithRetry(ctx, EventTypeCatalogDeleted, event)
+	return p.publish(ctx, EventTypeCatalogDeleted, sku, map[string]interface{}{
+		"sku": sku,
+	})
 }
 
-// publishWithRetry publishes an event with exponential backoff retry
-func (p *Publisher) publishWithRetry(ctx context.Context, routingKey string, event Event) error {
-	body, err := json.Marshal(event)
-	if err != nil {
-		p.log.Error("Failed to marshal event", zap.Error(err))
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
-
-	backoff := initialBackoff
-	var lastErr error
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-			}
-		}
-
-		// Publish with confirmation
-		confirms := p.channel.NotifyPublish(make(chan amqp.Confirmation, 1))
-
-		err := p.channel.PublishWithContext(
-			ctx,
-			exchangeName,
-			routingKey,
-			false, // mandatory
-			false, // immediate
-			amqp.Publishing{
-				ContentType:  "application/json",
-				DeliveryMode: amqp.Persistent,
-				Timestamp:    time.Now(),
-				MessageId:    event.EventID,
-				Body:         body,
-				Headers: amqp.Table{
-					"event_type":    event.EventType,
-					"event_version": event.EventVersion,
-				},
-			},
-		)
-
-		if err != nil {
-			lastErr = err
-			p.log.Warn("Failed to publish event, retrying",
-				zap.Int("attempt", attempt+1),
-				zap.Error(err),
-			)
-			continue
-		}
-
-		// Wait for confirmation
-		select {
-		case confirm := <-confirms:
-			if confirm.Ack {
-				p.log.Info("Event published successfully",
-					zap.String("event_id", event.EventID),
-					zap.String("event_type", event.EventType),
-					zap.String("routing_key", routingKey),
-				)
-				return nil
-			}
-			lastErr = fmt.Errorf("event not acknowledged")
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(5 * time.Second):
-			lastErr = fmt.Errorf("confirmation timeout")
-		}
-
-		p.log.Warn("Event publish not confirmed, retrying",
-			zap.Int("attempt", attempt+1),
-			zap.Error(lastErr),
-		)
-	}
+// PublishOrderConfirmed publishes an order confirmed event, once a
+// saga.Def has committed orderID's stock reservation.
+func (p *Publisher) PublishOrderConfirmed(ctx context.Context, orderID string) error {
+	return p.publish(ctx, EventTypeOrderConfirmed, orderID, map[string]interface{}{
+		"order_id": orderID,
+	})
+}
 
-	p.log.Error("Failed to publish event after retries",
-		zap.String("event_id", event.EventID),
-		zap.String("event_type", event.EventType),
-		zap.Int("attempts", maxRetries),
-		zap.Error(lastErr),
-	)
-	return fmt.Errorf("failed to publish event after %d attempts: %w", maxRetries, lastErr)
+// PublishRaw wraps an already-serialized payload (e.g. an outbox row's
+// JSON body) in a CloudEvent and publishes it on routingKey, without
+// re-marshaling it into a new payload shape. Used by OutboxDispatcher,
+// which only has the raw bytes a repo enqueued, not the typed payload.
+func (p *Publisher) PublishRaw(ctx context.Context, routingKey, subject string, data json.RawMessage) error {
+	return p.publish(ctx, routingKey, subject, data)
 }
 
-// IsHealthy checks if the publisher connection is healthy
+// IsHealthy checks if the broker connection is healthy
 func (p *Publisher) IsHealthy() bool {
-	return p.conn != nil && !p.conn.IsClosed()
+	return p.broker != nil && p.broker.IsHealthy()
 }
 
-// Close closes the publisher connection
+// Close closes the broker connection
 func (p *Publisher) Close() error {
-	if p.channel != nil {
-		if err := p.channel.Close(); err != nil {
-			p.log.Error("Failed to close channel", zap.Error(err))
-		}
-	}
-	if p.conn != nil {
-		if err := p.conn.Close(); err != nil {
-			p.log.Error("Failed to close connection", zap.Error(err))
-			return err
-		}
+	if p.broker != nil {
+		return p.broker.Close()
 	}
-	p.log.Info("Publisher closed")
 	return nil
 }
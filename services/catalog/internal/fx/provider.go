@@ -0,0 +1,122 @@
+// Package fx converts book prices between currencies, so a catalog
+// listing, a single book, or a search result can be requested in a
+// display currency different from the one the price is stored in.
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrRateUnavailable is returned when a Provider has no rate for one of
+// the requested currencies.
+var ErrRateUnavailable = errors.New("fx rate unavailable")
+
+// Provider converts between currencies. Implementations must be safe for
+// concurrent use.
+type Provider interface {
+	// Rate reports how many units of to one unit of from is worth, e.g.
+	// Rate(ctx, "USD", "EUR") might return 0.92. Rate(ctx, x, x) is
+	// always 1 for any currency x, even one the Provider has never seen.
+	Rate(ctx context.Context, from, to string) (float64, error)
+}
+
+// BaseCurrency is the currency InMemoryProvider's rates are quoted
+// against. It has no relation to Book.Price's own currency, which is
+// stored as-is and only converted at read time.
+const BaseCurrency = "USD"
+
+// FetchFunc retrieves a fresh snapshot of exchange rates, each expressed
+// as "units of this currency per one unit of BaseCurrency", from an
+// external source (a REST API, a message feed, ...). It is injected into
+// RunRefresher so InMemoryProvider never depends on a specific FX data
+// source.
+type FetchFunc func(ctx context.Context) (map[string]float64, error)
+
+// InMemoryProvider holds a snapshot of exchange rates against
+// BaseCurrency, safe for concurrent reads and for wholesale replacement
+// by RunRefresher.
+type InMemoryProvider struct {
+	mu    sync.RWMutex
+	rates map[string]float64 // currency -> units per 1 BaseCurrency
+}
+
+// NewInMemoryProvider returns a Provider seeded with initialRates
+// (currency -> units per 1 BaseCurrency). BaseCurrency itself does not
+// need an entry; it is always implicitly 1.
+func NewInMemoryProvider(initialRates map[string]float64) *InMemoryProvider {
+	p := &InMemoryProvider{}
+	p.SetRates(initialRates)
+	return p
+}
+
+// SetRates atomically replaces the provider's rate table, e.g. after a
+// periodic refresh from an external source.
+func (p *InMemoryProvider) SetRates(rates map[string]float64) {
+	snapshot := make(map[string]float64, len(rates)+1)
+	for currency, rate := range rates {
+		snapshot[currency] = rate
+	}
+	snapshot[BaseCurrency] = 1
+
+	p.mu.Lock()
+	p.rates = snapshot
+	p.mu.Unlock()
+}
+
+// Rate converts from -> to via BaseCurrency as a pivot.
+func (p *InMemoryProvider) Rate(ctx context.Context, from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	p.mu.RLock()
+	fromRate, fromOK := p.rates[from]
+	toRate, toOK := p.rates[to]
+	p.mu.RUnlock()
+
+	if !fromOK {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, from)
+	}
+	if !toOK {
+		return 0, fmt.Errorf("%w: %s", ErrRateUnavailable, to)
+	}
+
+	return toRate / fromRate, nil
+}
+
+// RunRefresher polls fetch every interval and loads its result into
+// provider until ctx is cancelled. It refreshes once immediately so
+// rates are current before the first tick. A failed fetch is logged and
+// the previous snapshot is kept, rather than the caller blocking or
+// conversions failing, since a stale rate is almost always more useful
+// than none.
+func RunRefresher(ctx context.Context, provider *InMemoryProvider, fetch FetchFunc, interval time.Duration, log *zap.Logger) {
+	refresh := func() {
+		rates, err := fetch(ctx)
+		if err != nil {
+			log.Warn("Failed to refresh FX rates, keeping previous snapshot", zap.Error(err))
+			return
+		}
+		provider.SetRates(rates)
+	}
+
+	refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
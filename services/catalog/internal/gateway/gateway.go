@@ -0,0 +1,56 @@
+// Package gateway mounts the catalog service's REST/JSON bridge on top
+// of the gRPC server, so the same CatalogServer that serves gRPC also
+// answers GET /v1/books/{sku}, GET /v1/books, GET /v1/books/search, and
+// the write endpoints over HTTP/JSON. The routes themselves (the
+// google.api.http annotations) live on the .proto service definition and
+// are compiled into catalogpb's RegisterCatalogServiceHandlerServer by
+// protoc-gen-grpc-gateway; this package only wires that generated
+// handler into our own ServeMux and propagates the headers we care
+// about into gRPC metadata.
+package gateway
+
+import (
+	"context"
+	"net/http"
+
+	catalogpb "github.com/bookstore/contracts/gen/go/catalog"
+	grpcserver "github.com/bookstore/services/catalog/internal/grpc"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"go.uber.org/zap"
+)
+
+// propagatedHeaders are forwarded from the incoming HTTP request into
+// incoming gRPC metadata under the same key, so CatalogServer's handlers
+// see the same trace/request IDs a native gRPC caller would have set.
+// Gateway requests call CatalogServer in-process, so the gRPC server's
+// interceptors (LoggingInterceptor) and stats handler do not run for them.
+var propagatedHeaders = []string{"traceparent", "x-request-id"}
+
+func headerMatcher(header string) (string, bool) {
+	for _, h := range propagatedHeaders {
+		if http.CanonicalHeaderKey(header) == http.CanonicalHeaderKey(h) {
+			return h, true
+		}
+	}
+	return runtime.DefaultHeaderMatcher(header)
+}
+
+// NewMux returns an HTTP handler for the catalog REST surface, serving
+// it in-process against catalogService directly (no extra network hop
+// through a gRPC client), using the same marshaling and routing
+// RegisterCatalogServiceHandlerServer would give a dialed client.
+func NewMux(ctx context.Context, catalogService *grpcserver.CatalogServer, log *zap.Logger) (http.Handler, error) {
+	mux := runtime.NewServeMux(
+		runtime.WithIncomingHeaderMatcher(headerMatcher),
+		runtime.WithErrorHandler(func(ctx context.Context, mux *runtime.ServeMux, marshaler runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+			log.Warn("REST gateway request failed", zap.String("path", r.URL.Path), zap.Error(err))
+			runtime.DefaultHTTPErrorHandler(ctx, mux, marshaler, w, r, err)
+		}),
+	)
+
+	if err := catalogpb.RegisterCatalogServiceHandlerServer(ctx, mux, catalogService); err != nil {
+		return nil, err
+	}
+
+	return mux, nil
+}
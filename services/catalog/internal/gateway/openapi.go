@@ -0,0 +1,20 @@
+package gateway
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is catalog.swagger.json, produced alongside the generated
+// gateway handlers by protoc-gen-openapiv2 from catalog.proto.
+//
+//go:embed openapi/catalog.swagger.json
+var openAPISpec []byte
+
+// ServeOpenAPI answers /openapi.json with the embedded spec, so browsers,
+// curl, and non-Go clients can discover the REST surface without
+// needing the .proto source.
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPISpec)
+}
@@ -7,10 +7,12 @@ import (
 	"github.com/bookstore/services/catalog/internal/clients"
 	"github.com/bookstore/services/catalog/internal/db"
 	"github.com/bookstore/services/catalog/internal/events"
+	"github.com/bookstore/services/catalog/internal/fx"
 	"github.com/bookstore/services/catalog/internal/repo"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	// Import generated proto files
@@ -22,17 +24,20 @@ import (
 type CatalogServer struct {
 	catalogpb.UnimplementedCatalogServiceServer
 	repo            *repo.CatalogRepository
-	publisher       *events.Publisher
+	publisher       events.EventPublisher
 	inventoryClient *clients.InventoryClient
+	fxProvider      fx.Provider
 	log             *zap.Logger
 }
 
-// NewCatalogServer creates a new catalog gRPC server
-func NewCatalogServer(repo *repo.CatalogRepository, publisher *events.Publisher, inventoryClient *clients.InventoryClient, log *zap.Logger) *CatalogServer {
+// NewCatalogServer creates a new catalog gRPC server. fxProvider may be
+// nil; it is only consulted when a request sets display_currency.
+func NewCatalogServer(repo *repo.CatalogRepository, publisher events.EventPublisher, inventoryClient *clients.InventoryClient, fxProvider fx.Provider, log *zap.Logger) *CatalogServer {
 	return &CatalogServer{
 		repo:            repo,
 		publisher:       publisher,
 		inventoryClient: inventoryClient,
+		fxProvider:      fxProvider,
 		log:             log,
 	}
 }
@@ -64,6 +69,9 @@ func (s *CatalogServer) ListBooks(ctx context.Context, req *catalogpb.ListBooksR
 		req.GetActiveOnly(),
 		req.GetMinPrice(),
 		req.GetMaxPrice(),
+		uint(req.GetCategoryId()),
+		req.GetCategorySlug(),
+		req.GetDisplayCurrency(),
 	)
 	if err != nil {
 		s.log.Error("Failed to list books", zap.Error(err))
@@ -73,7 +81,7 @@ func (s *CatalogServer) ListBooks(ctx context.Context, req *catalogpb.ListBooksR
 	// Convert to proto
 	pbBooks := make([]*catalogpb.Book, len(books))
 	for i, book := range books {
-		pbBooks[i] = s.bookToProto(ctx, book)
+		pbBooks[i] = s.bookToProto(ctx, book, req.GetDisplayCurrency())
 	}
 
 	// Calculate total pages
@@ -109,7 +117,7 @@ func (s *CatalogServer) GetBook(ctx context.Context, req *catalogpb.GetBookReque
 	}
 
 	return &catalogpb.GetBookResponse{
-		Book: s.bookToProto(ctx, book),
+		Book: s.bookToProto(ctx, book, req.GetDisplayCurrency()),
 	}, nil
 }
 
@@ -132,30 +140,10 @@ func (s *CatalogServer) CreateBook(ctx context.Context, req *catalogpb.CreateBoo
 		return nil, status.Error(codes.Internal, "failed to create book")
 	}
 
-	// Publish event (async, don't fail request if event publishing fails)
-	go func() {
-		eventCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		if err := s.publisher.PublishBookCreated(
-			eventCtx,
-			book.SKU,
-			book.Title,
-			book.Author,
-			book.Category,
-			book.Currency,
-			book.Price,
-			book.Active,
-		); err != nil {
-			s.log.Error("Failed to publish book created event",
-				zap.String("sku", book.SKU),
-				zap.Error(err),
-			)
-		}
-	}()
-
+	// The catalog.created event was enqueued in the outbox in the same
+	// transaction as the insert above; OutboxDispatcher delivers it.
 	return &catalogpb.CreateBookResponse{
-		Book: s.bookToProto(ctx, book),
+		Book: s.bookToProto(ctx, book, ""),
 	}, nil
 }
 
@@ -179,21 +167,9 @@ func (s *CatalogServer) UpdateBook(ctx context.Context, req *catalogpb.UpdateBoo
 		return nil, status.Error(codes.Internal, "failed to update book")
 	}
 
-	// Publish event only if fields changed (async)
-	if len(fieldsChanged) > 0 {
-		go func() {
-			eventCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-
-			updates := buildUpdatePayload(book, fieldsChanged)
-			if err := s.publisher.PublishBookUpdated(eventCtx, book.SKU, fieldsChanged, updates); err != nil {
-				s.log.Error("Failed to publish book updated event",
-					zap.String("sku", book.SKU),
-					zap.Error(err),
-				)
-			}
-		}()
-	}
+	// If fieldsChanged is non-empty, the catalog.updated event was
+	// enqueued in the outbox in the same transaction as the update above;
+	// OutboxDispatcher delivers it.
 
 	// Get updated book
 	updatedBook, err := s.repo.GetBook(ctx, book.SKU)
@@ -203,7 +179,7 @@ func (s *CatalogServer) UpdateBook(ctx context.Context, req *catalogpb.UpdateBoo
 	}
 
 	return &catalogpb.UpdateBookResponse{
-		Book: s.bookToProto(ctx, updatedBook),
+		Book: s.bookToProto(ctx, updatedBook, ""),
 	}, nil
 }
 
@@ -224,16 +200,19 @@ func (s *CatalogServer) SearchBooks(ctx context.Context, req *catalogpb.SearchBo
 	}
 
 	// Search books
-	books, total, err := s.repo.SearchBooks(ctx, req.GetQuery(), page, pageSize, req.GetCategory())
+	results, total, err := s.repo.SearchBooks(ctx, req.GetQuery(), page, pageSize, req.GetCategory(), req.GetLanguage())
 	if err != nil {
 		s.log.Error("Failed to search books", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to search books")
 	}
 
 	// Convert to proto
-	pbBooks := make([]*catalogpb.Book, len(books))
-	for i, book := range books {
-		pbBooks[i] = s.bookToProto(ctx, book)
+	pbBooks := make([]*catalogpb.Book, len(results))
+	for i, result := range results {
+		pb := s.bookToProto(ctx, result.Book, req.GetDisplayCurrency())
+		pb.Score = result.Rank
+		pb.Highlight = result.Highlight
+		pbBooks[i] = pb
 	}
 
 	// Calculate total pages
@@ -253,6 +232,157 @@ func (s *CatalogServer) SearchBooks(ctx context.Context, req *catalogpb.SearchBo
 	}, nil
 }
 
+// CreateCategory creates a new taxonomy node
+func (s *CatalogServer) CreateCategory(ctx context.Context, req *catalogpb.CreateCategoryRequest) (*catalogpb.CreateCategoryResponse, error) {
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if req.GetSlug() == "" {
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+
+	var parentID *uint
+	if req.GetParentId() != 0 {
+		id := uint(req.GetParentId())
+		parentID = &id
+	}
+
+	category, err := s.repo.CreateCategory(ctx, req.GetName(), req.GetSlug(), parentID)
+	if err != nil {
+		if err == repo.ErrCategorySlugExists {
+			return nil, status.Error(codes.AlreadyExists, "category slug already exists")
+		}
+		s.log.Error("Failed to create category", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to create category")
+	}
+
+	return &catalogpb.CreateCategoryResponse{
+		Category: categoryToProto(category),
+	}, nil
+}
+
+// ListCategories returns every category in the taxonomy
+func (s *CatalogServer) ListCategories(ctx context.Context, req *catalogpb.ListCategoriesRequest) (*catalogpb.ListCategoriesResponse, error) {
+	categories, err := s.repo.ListCategories(ctx)
+	if err != nil {
+		s.log.Error("Failed to list categories", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list categories")
+	}
+
+	pbCategories := make([]*catalogpb.Category, len(categories))
+	for i, category := range categories {
+		pbCategories[i] = categoryToProto(category)
+	}
+
+	return &catalogpb.ListCategoriesResponse{Categories: pbCategories}, nil
+}
+
+// AttachCategories links a book to one or more categories
+func (s *CatalogServer) AttachCategories(ctx context.Context, req *catalogpb.AttachCategoriesRequest) (*catalogpb.AttachCategoriesResponse, error) {
+	if req.GetSku() == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is required")
+	}
+
+	if err := s.repo.AttachCategories(ctx, req.GetSku(), protoToCategoryIDs(req.GetCategoryIds())); err != nil {
+		s.log.Error("Failed to attach categories", zap.String("sku", req.GetSku()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to attach categories")
+	}
+
+	// The category.attached events were enqueued in the outbox in the
+	// same transaction as the links above; OutboxDispatcher delivers them.
+	return &catalogpb.AttachCategoriesResponse{}, nil
+}
+
+// DetachCategories unlinks a book from one or more categories
+func (s *CatalogServer) DetachCategories(ctx context.Context, req *catalogpb.DetachCategoriesRequest) (*catalogpb.DetachCategoriesResponse, error) {
+	if req.GetSku() == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is required")
+	}
+
+	if err := s.repo.DetachCategories(ctx, req.GetSku(), protoToCategoryIDs(req.GetCategoryIds())); err != nil {
+		s.log.Error("Failed to detach categories", zap.String("sku", req.GetSku()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to detach categories")
+	}
+
+	// The category.detached events were enqueued in the outbox in the
+	// same transaction as the links removed above; OutboxDispatcher
+	// delivers them.
+	return &catalogpb.DetachCategoriesResponse{}, nil
+}
+
+// GetCategoryStats returns per-category book counts for the taxonomy
+func (s *CatalogServer) GetCategoryStats(ctx context.Context, req *commonpb.Empty) (*catalogpb.GetCategoryStatsResponse, error) {
+	stats, err := s.repo.GetCategoryStats(ctx)
+	if err != nil {
+		s.log.Error("Failed to get category stats", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get category stats")
+	}
+
+	pbStats := make([]*catalogpb.CategoryStats, len(stats))
+	for i, stat := range stats {
+		pbStats[i] = &catalogpb.CategoryStats{
+			CategoryId: uint32(stat.CategoryID),
+			Name:       stat.Name,
+			Slug:       stat.Slug,
+			Total:      stat.Total,
+			Active:     stat.Active,
+		}
+	}
+
+	return &catalogpb.GetCategoryStatsResponse{Stats: pbStats}, nil
+}
+
+// ToggleBookActive flips a book's published/active state and returns the
+// new value. The flip, its active_history row, and its outbox event are
+// all committed atomically by repo.CatalogRepository.ToggleActive.
+func (s *CatalogServer) ToggleBookActive(ctx context.Context, req *catalogpb.ToggleBookActiveRequest) (*catalogpb.ToggleBookActiveResponse, error) {
+	if req.GetSku() == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is required")
+	}
+
+	newActive, err := s.repo.ToggleActive(ctx, req.GetSku(), changedByFromContext(ctx))
+	if err != nil {
+		if err == repo.ErrBookNotFound {
+			return nil, status.Error(codes.NotFound, "book not found")
+		}
+		s.log.Error("Failed to toggle book active state", zap.String("sku", req.GetSku()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to toggle book active state")
+	}
+
+	return &catalogpb.ToggleBookActiveResponse{
+		Sku:    req.GetSku(),
+		Active: newActive,
+	}, nil
+}
+
+// ListActiveHistory returns the audit trail of active-state flips for a
+// book, most recent first, so admins can review who changed its
+// visibility and when.
+func (s *CatalogServer) ListActiveHistory(ctx context.Context, req *catalogpb.ListActiveHistoryRequest) (*catalogpb.ListActiveHistoryResponse, error) {
+	if req.GetSku() == "" {
+		return nil, status.Error(codes.InvalidArgument, "sku is required")
+	}
+
+	history, err := s.repo.ListActiveHistory(ctx, req.GetSku())
+	if err != nil {
+		s.log.Error("Failed to list active history", zap.String("sku", req.GetSku()), zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to list active history")
+	}
+
+	pbHistory := make([]*catalogpb.ActiveHistoryEntry, len(history))
+	for i, entry := range history {
+		pbHistory[i] = &catalogpb.ActiveHistoryEntry{
+			Sku:       entry.SKU,
+			From:      entry.FromActive,
+			To:        entry.ToActive,
+			ChangedAt: entry.ChangedAt.Unix(),
+			ChangedBy: entry.ChangedBy,
+		}
+	}
+
+	return &catalogpb.ListActiveHistoryResponse{History: pbHistory}, nil
+}
+
 // Health performs a health check
 func (s *CatalogServer) Health(ctx context.Context, req *commonpb.Empty) (*commonpb.HealthStatus, error) {
 	// This is handled by the dedicated health server
@@ -265,16 +395,50 @@ func (s *CatalogServer) Health(ctx context.Context, req *commonpb.Empty) (*commo
 
 // Helper functions
 
-func (s *CatalogServer) bookToProto(ctx context.Context, book *db.Book) *catalogpb.Book {
+// changedByFromContext identifies the caller for the active_history audit
+// trail from the incoming request's "x-user-id" metadata, falling back
+// to "system" for callers that don't set it (internal jobs, older
+// clients).
+func changedByFromContext(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-user-id"); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return "system"
+}
+
+// bookToProto converts book to its proto representation. If
+// displayCurrency is set and differs from book's own stored currency, the
+// price is converted via s.fxProvider; a conversion failure is logged and
+// falls back to the book's stored currency rather than failing the whole
+// request.
+func (s *CatalogServer) bookToProto(ctx context.Context, book *db.Book, displayCurrency string) *catalogpb.Book {
+	price := &commonpb.Money{
+		Currency:      book.Currency,
+		Amount:        book.Price,
+		DecimalPlaces: 2,
+	}
+
+	if displayCurrency != "" && displayCurrency != book.Currency {
+		if s.fxProvider == nil {
+			s.log.Warn("display_currency requested but no FX provider is configured", zap.String("sku", book.SKU))
+		} else if rate, err := s.fxProvider.Rate(ctx, book.Currency, displayCurrency); err != nil {
+			s.log.Warn("Failed to convert price, returning stored currency", zap.String("sku", book.SKU), zap.Error(err))
+		} else {
+			price = &commonpb.Money{
+				Currency:      displayCurrency,
+				Amount:        int64(float64(book.Price) * rate),
+				DecimalPlaces: 2,
+			}
+		}
+	}
+
 	pb := &catalogpb.Book{
-		Sku:    book.SKU,
-		Title:  book.Title,
-		Author: book.Author,
-		Price: &commonpb.Money{
-			Currency:      book.Currency,
-			Amount:        book.Price,
-			DecimalPlaces: 2,
-		},
+		Sku:         book.SKU,
+		Title:       book.Title,
+		Author:      book.Author,
+		Price:       price,
 		Category:    book.Category,
 		Description: book.Description,
 		CreatedAt:   book.CreatedAt.Unix(),
@@ -320,6 +484,26 @@ func protoToBook(pb *catalogpb.Book) *db.Book {
 	return book
 }
 
+func categoryToProto(category *db.Category) *catalogpb.Category {
+	pb := &catalogpb.Category{
+		Id:   uint32(category.ID),
+		Name: category.Name,
+		Slug: category.Slug,
+	}
+	if category.ParentID != nil {
+		pb.ParentId = uint32(*category.ParentID)
+	}
+	return pb
+}
+
+func protoToCategoryIDs(ids []uint32) []uint {
+	categoryIDs := make([]uint, len(ids))
+	for i, id := range ids {
+		categoryIDs[i] = uint(id)
+	}
+	return categoryIDs
+}
+
 func validateBook(book *catalogpb.Book) error {
 	if book == nil {
 		return status.Error(codes.InvalidArgument, "book is required")
@@ -369,29 +553,6 @@ func validateBookForCreate(book *catalogpb.Book) error {
 	return nil
 }
 
-func buildUpdatePayload(book *db.Book, fieldsChanged []string) map[string]interface{} {
-	payload := make(map[string]interface{})
-	for _, field := range fieldsChanged {
-		switch field {
-		case "title":
-			payload["title"] = book.Title
-		case "author":
-			payload["author"] = book.Author
-		case "price":
-			payload["price"] = book.Price
-		case "currency":
-			payload["currency"] = book.Currency
-		case "category":
-			payload["category"] = book.Category
-		case "description":
-			payload["description"] = book.Description
-		case "active":
-			payload["active"] = book.Active
-		}
-	}
-	return payload
-}
-
 // LoggingInterceptor logs all gRPC requests
 func LoggingInterceptor(log *zap.Logger) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
@@ -6,8 +6,10 @@ import (
 	"fmt"
 
 	"github.com/bookstore/services/catalog/internal/db"
+	"github.com/bookstore/services/catalog/internal/fx"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -22,20 +24,41 @@ var (
 type CatalogRepository struct {
 	db  *db.DB
 	log *zap.Logger
+	fx  fx.Provider
 }
 
-// NewCatalogRepository creates a new catalog repository
-func NewCatalogRepository(database *db.DB, logger *zap.Logger) *CatalogRepository {
+// NewCatalogRepository creates a new catalog repository. fxProvider may be
+// nil; it is only consulted when ListBooks is asked to filter by price
+// range with a non-empty displayCurrency, and such a request fails if it
+// is nil.
+func NewCatalogRepository(database *db.DB, logger *zap.Logger, fxProvider fx.Provider) *CatalogRepository {
 	return &CatalogRepository{
 		db:  database,
 		log: logger,
+		fx:  fxProvider,
 	}
 }
 
-// ListBooks returns a paginated list of books with optional filters
-func (r *CatalogRepository) ListBooks(ctx context.Context, page, pageSize int32, category, author string, activeOnly bool, minPrice, maxPrice float64) ([]*db.Book, int64, error) {
+// ListBooks returns a paginated list of books with optional filters.
+// categoryID and categorySlug filter through the book_categories join
+// table, independently of the legacy category string column; either may
+// be left zero/empty. minPrice and maxPrice are interpreted in
+// displayCurrency; if displayCurrency is empty they are interpreted in
+// each book's own stored currency, matching the pre-FX behavior.
+func (r *CatalogRepository) ListBooks(ctx context.Context, page, pageSize int32, category, author string, activeOnly bool, minPrice, maxPrice float64, categoryID uint, categorySlug string, displayCurrency string) ([]*db.Book, int64, error) {
 	query := r.db.WithContext(ctx).Model(&db.Book{})
 
+	if categoryID != 0 || categorySlug != "" {
+		query = query.Joins("JOIN book_categories ON book_categories.book_sku = books.sku").
+			Joins("JOIN categories ON categories.id = book_categories.category_id")
+		if categoryID != 0 {
+			query = query.Where("categories.id = ?", categoryID)
+		}
+		if categorySlug != "" {
+			query = query.Where("categories.slug = ?", categorySlug)
+		}
+	}
+
 	// Apply filters
 	if category != "" {
 		query = query.Where("category = ?", category)
@@ -46,11 +69,13 @@ func (r *CatalogRepository) ListBooks(ctx context.Context, page, pageSize int32,
 	if activeOnly {
 		query = query.Where("active = ?", true)
 	}
-	if minPrice > 0 {
-		query = query.Where("price >= ?", int64(minPrice*100))
-	}
-	if maxPrice > 0 {
-		query = query.Where("price <= ?", int64(maxPrice*100))
+	if minPrice > 0 || maxPrice > 0 {
+		clause, args, err := r.priceRangeClause(ctx, minPrice, maxPrice, displayCurrency)
+		if err != nil {
+			r.log.Error("Failed to build price range filter", zap.Error(err))
+			return nil, 0, err
+		}
+		query = query.Where(clause, args...)
 	}
 
 	// Count total
@@ -63,7 +88,7 @@ func (r *CatalogRepository) ListBooks(ctx context.Context, page, pageSize int32,
 	// Apply pagination
 	offset := (page - 1) * pageSize
 	var books []*db.Book
-	if err := query.Offset(int(offset)).Limit(int(pageSize)).Order("created_at DESC").Find(&books).Error; err != nil {
+	if err := query.Offset(int(offset)).Limit(int(pageSize)).Order("books.created_at DESC").Find(&books).Error; err != nil {
 		r.log.Error("Failed to list books", zap.Error(err))
 		return nil, 0, err
 	}
@@ -71,6 +96,75 @@ func (r *CatalogRepository) ListBooks(ctx context.Context, page, pageSize int32,
 	return books, total, nil
 }
 
+// priceRangeClause builds a WHERE clause (and its args) restricting
+// price to [minPrice, maxPrice] (either bound may be zero to mean
+// unbounded). When displayCurrency is empty, the bounds are applied
+// directly against the stored price column, as they always were before
+// display currencies existed. When displayCurrency is set, the bounds
+// are converted into every currency actually present in the catalog via
+// r.fx, and OR'd together per-currency, since books are never converted
+// row-by-row in Go: that would defeat the database's own pagination and
+// counting.
+func (r *CatalogRepository) priceRangeClause(ctx context.Context, minPrice, maxPrice float64, displayCurrency string) (string, []interface{}, error) {
+	if displayCurrency == "" {
+		clause, args := "", []interface{}{}
+		if minPrice > 0 {
+			clause += "price >= ?"
+			args = append(args, int64(minPrice*100))
+		}
+		if maxPrice > 0 {
+			if clause != "" {
+				clause += " AND "
+			}
+			clause += "price <= ?"
+			args = append(args, int64(maxPrice*100))
+		}
+		return clause, args, nil
+	}
+
+	if r.fx == nil {
+		return "", nil, fmt.Errorf("price range filter requested in %s but no FX provider is configured", displayCurrency)
+	}
+
+	var currencies []string
+	if err := r.db.WithContext(ctx).Model(&db.Book{}).Distinct().Pluck("currency", &currencies).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to list catalog currencies: %w", err)
+	}
+
+	clause, args := "", []interface{}{}
+	for _, currency := range currencies {
+		rate, err := r.fx.Rate(ctx, displayCurrency, currency)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to convert price range to %s: %w", currency, err)
+		}
+
+		perCurrency := "currency = ?"
+		perCurrencyArgs := []interface{}{currency}
+		if minPrice > 0 {
+			perCurrency += " AND price >= ?"
+			perCurrencyArgs = append(perCurrencyArgs, int64(minPrice*rate*100))
+		}
+		if maxPrice > 0 {
+			perCurrency += " AND price <= ?"
+			perCurrencyArgs = append(perCurrencyArgs, int64(maxPrice*rate*100))
+		}
+
+		if clause != "" {
+			clause += " OR "
+		}
+		clause += "(" + perCurrency + ")"
+		args = append(args, perCurrencyArgs...)
+	}
+
+	if clause == "" {
+		// No books yet, so no currency can match; make the filter
+		// unsatisfiable rather than accidentally matching everything.
+		return "1 = 0", nil, nil
+	}
+
+	return clause, args, nil
+}
+
 // GetBook retrieves a book by SKU
 func (r *CatalogRepository) GetBook(ctx context.Context, sku string) (*db.Book, error) {
 	var book db.Book
@@ -109,8 +203,25 @@ func (r *CatalogRepository) CreateBook(ctx context.Context, book *db.Book) error
 		return err
 	}
 
-	// Create book
-	if err := r.db.WithContext(ctx).Create(book).Error; err != nil {
+	// Create the book and enqueue its catalog.created event in the same
+	// transaction, so the event is never lost even if the broker is down
+	// or the process crashes right after commit; OutboxDispatcher
+	// delivers it separately.
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, book.SKU, outboxEventCatalogCreated, map[string]interface{}{
+			"sku":      book.SKU,
+			"title":    book.Title,
+			"author":   book.Author,
+			"price":    book.Price,
+			"currency": book.Currency,
+			"category": book.Category,
+			"active":   book.Active,
+		})
+	})
+	if err != nil {
 		r.log.Error("Failed to create book", zap.String("sku", book.SKU), zap.Error(err))
 		return err
 	}
@@ -122,22 +233,22 @@ func (r *CatalogRepository) CreateBook(ctx context.Context, book *db.Book) error
 // generateNextSKU generates the next sequential SKU (BOOK-001, BOOK-002, etc.)
 func (r *CatalogRepository) generateNextSKU(ctx context.Context) (string, error) {
 	var lastBook db.Book
-	
+
 	// Get the last book ordered by SKU (descending) that matches BOOK-% pattern
 	err := r.db.WithContext(ctx).
 		Where("sku LIKE ?", "BOOK-%").
 		Order("sku DESC").
 		First(&lastBook).Error
-	
+
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		// No books exist yet, start with BOOK-001
 		return "BOOK-001", nil
 	}
-	
+
 	if err != nil {
 		return "", fmt.Errorf("failed to get last book: %w", err)
 	}
-	
+
 	// Extract number from last SKU (e.g., "BOOK-015" -> 15)
 	var lastNum int
 	_, err = fmt.Sscanf(lastBook.SKU, "BOOK-%d", &lastNum)
@@ -149,7 +260,7 @@ func (r *CatalogRepository) generateNextSKU(ctx context.Context) (string, error)
 		}
 		return fmt.Sprintf("BOOK-%03d", count+1), nil
 	}
-	
+
 	// Generate next SKU
 	nextNum := lastNum + 1
 	return fmt.Sprintf("BOOK-%03d", nextNum), nil
@@ -191,8 +302,23 @@ func (r *CatalogRepository) UpdateBook(ctx context.Context, book *db.Book, updat
 		}
 	}
 
-	// Update book
-	if err := r.db.WithContext(ctx).Model(&db.Book{}).Where("sku = ?", book.SKU).Updates(updates).Error; err != nil {
+	// Update the book and enqueue its catalog.updated event in the same
+	// transaction (see CreateBook for why).
+	outboxPayload := map[string]interface{}{
+		"sku":            book.SKU,
+		"fields_changed": fieldsChanged,
+	}
+	for field, value := range updates {
+		outboxPayload[field] = value
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.Book{}).Where("sku = ?", book.SKU).Updates(updates).Error; err != nil {
+			return err
+		}
+		return enqueueOutbox(tx, book.SKU, outboxEventCatalogUpdated, outboxPayload)
+	})
+	if err != nil {
 		r.log.Error("Failed to update book", zap.String("sku", book.SKU), zap.Error(err))
 		return nil, err
 	}
@@ -201,50 +327,190 @@ func (r *CatalogRepository) UpdateBook(ctx context.Context, book *db.Book, updat
 	return fieldsChanged, nil
 }
 
-// SearchBooks performs full-text search on books
-func (r *CatalogRepository) SearchBooks(ctx context.Context, query string, page, pageSize int32, category string) ([]*db.Book, int64, error) {
-	// Build search query using PostgreSQL full-text search
-	searchQuery := r.db.WithContext(ctx).Model(&db.Book{}).
-		Where("to_tsvector('english', title || ' ' || author) @@ plainto_tsquery('english', ?)", query)
+// searchLanguages maps the language values SearchBooks accepts on its API
+// to the Postgres text search configuration name; any other value falls
+// back to "english".
+var searchLanguages = map[string]string{
+	"english": "english",
+	"spanish": "spanish",
+}
+
+// SearchResult pairs a matched book with its search ranking and a
+// highlighted snippet of the text that matched.
+type SearchResult struct {
+	Book      *db.Book
+	Rank      float64
+	Highlight string
+}
+
+// SearchBooks performs full-text search against books.search_vector
+// (maintained by a database trigger, see createSearchVectorColumn),
+// ranked by ts_rank_cd and with a ts_headline snippet per result.
+// language selects the text search configuration ("english" or
+// "spanish"); any other value, including empty, falls back to "english".
+func (r *CatalogRepository) SearchBooks(ctx context.Context, query string, page, pageSize int32, category, language string) ([]*SearchResult, int64, error) {
+	config, ok := searchLanguages[language]
+	if !ok {
+		config = "english"
+	}
 
+	countQuery := r.db.WithContext(ctx).Model(&db.Book{}).
+		Where("search_vector @@ websearch_to_tsquery(?, ?)", config, query)
 	if category != "" {
-		searchQuery = searchQuery.Where("category = ?", category)
+		countQuery = countQuery.Where("category = ?", category)
 	}
 
-	// Count total
 	var total int64
-	if err := searchQuery.Count(&total).Error; err != nil {
+	if err := countQuery.Count(&total).Error; err != nil {
 		r.log.Error("Failed to count search results", zap.Error(err))
 		return nil, 0, err
 	}
 
-	// Apply pagination
 	offset := (page - 1) * pageSize
-	var books []*db.Book
-	if err := searchQuery.Offset(int(offset)).Limit(int(pageSize)).Find(&books).Error; err != nil {
+	rowsQuery := r.db.WithContext(ctx).Table("books").
+		Select("books.*, ts_rank_cd(search_vector, websearch_to_tsquery(?, ?)) AS rank, "+
+			"ts_headline(?, title || ' ' || coalesce(description, ''), websearch_to_tsquery(?, ?), 'MaxFragments=1') AS highlight",
+			config, query, config, config, query).
+		Where("search_vector @@ websearch_to_tsquery(?, ?)", config, query)
+	if category != "" {
+		rowsQuery = rowsQuery.Where("category = ?", category)
+	}
+
+	var rows []struct {
+		db.Book
+		Rank      float64
+		Highlight string
+	}
+	if err := rowsQuery.Order("rank DESC").Offset(int(offset)).Limit(int(pageSize)).Scan(&rows).Error; err != nil {
 		r.log.Error("Failed to search books", zap.Error(err))
 		return nil, 0, err
 	}
 
-	return books, total, nil
+	results := make([]*SearchResult, len(rows))
+	for i := range rows {
+		book := rows[i].Book
+		results[i] = &SearchResult{
+			Book:      &book,
+			Rank:      rows[i].Rank,
+			Highlight: rows[i].Highlight,
+		}
+	}
+
+	return results, total, nil
 }
 
-// DeleteBook soft deletes a book by setting active to false
+// DeleteBook soft deletes a book by setting active to false. It goes
+// through the same setActive path as ToggleActive, so the deletion
+// leaves an active_history row and a catalog.deleted event alongside the
+// book.deactivated event, instead of being a distinct, unaudited code
+// path.
 func (r *CatalogRepository) DeleteBook(ctx context.Context, sku string) error {
-	result := r.db.WithContext(ctx).Model(&db.Book{}).Where("sku = ?", sku).Update("active", false)
-	if result.Error != nil {
-		r.log.Error("Failed to delete book", zap.String("sku", sku), zap.Error(result.Error))
-		return result.Error
-	}
-
-	if result.RowsAffected == 0 {
-		return ErrBookNotFound
+	if err := r.setActive(ctx, sku, false, "system", outboxEventCatalogDeleted); err != nil {
+		r.log.Error("Failed to delete book", zap.String("sku", sku), zap.Error(err))
+		return err
 	}
 
 	r.log.Info("Book deleted", zap.String("sku", sku))
 	return nil
 }
 
+// ToggleActive atomically flips a book's Active flag, records the change
+// in active_history, and enqueues a book.activated/book.deactivated
+// outbox event, returning the book's new Active value. changedBy
+// identifies the caller (e.g. from request metadata) for the audit
+// trail.
+func (r *CatalogRepository) ToggleActive(ctx context.Context, sku, changedBy string) (bool, error) {
+	var newActive bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var book db.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sku = ?", sku).First(&book).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		newActive = !book.Active
+		return r.recordActiveChange(tx, sku, book.Active, newActive, changedBy)
+	})
+	if err != nil {
+		r.log.Error("Failed to toggle book active state", zap.String("sku", sku), zap.Error(err))
+		return false, err
+	}
+
+	r.log.Info("Book active state toggled", zap.String("sku", sku), zap.Bool("active", newActive))
+	return newActive, nil
+}
+
+// setActive drives a book's Active flag to exactly to, through the same
+// recordActiveChange path as ToggleActive. It is a no-op, not an error,
+// when the book is already in the desired state. Each of extraEvents is
+// also enqueued, with a {"sku": sku} payload, in the same transaction
+// whenever the flag actually changes.
+func (r *CatalogRepository) setActive(ctx context.Context, sku string, to bool, changedBy string, extraEvents ...string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var book db.Book
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sku = ?", sku).First(&book).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrBookNotFound
+			}
+			return err
+		}
+
+		if book.Active == to {
+			return nil
+		}
+		if err := r.recordActiveChange(tx, sku, book.Active, to, changedBy); err != nil {
+			return err
+		}
+		for _, eventType := range extraEvents {
+			if err := enqueueOutbox(tx, sku, eventType, map[string]interface{}{"sku": sku}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// recordActiveChange updates books.active, appends the active_history
+// row, and enqueues the corresponding outbox event, all within tx.
+func (r *CatalogRepository) recordActiveChange(tx *gorm.DB, sku string, from, to bool, changedBy string) error {
+	if err := tx.Model(&db.Book{}).Where("sku = ?", sku).Update("active", to).Error; err != nil {
+		return err
+	}
+
+	history := &db.ActiveHistory{
+		SKU:        sku,
+		FromActive: from,
+		ToActive:   to,
+		ChangedBy:  changedBy,
+	}
+	if err := tx.Create(history).Error; err != nil {
+		return err
+	}
+
+	eventType := outboxEventBookDeactivated
+	if to {
+		eventType = outboxEventBookActivated
+	}
+	return enqueueOutbox(tx, sku, eventType, map[string]interface{}{
+		"sku":        sku,
+		"from":       from,
+		"to":         to,
+		"changed_by": changedBy,
+	})
+}
+
+// ListActiveHistory returns sku's active_history rows, most recent first.
+func (r *CatalogRepository) ListActiveHistory(ctx context.Context, sku string) ([]*db.ActiveHistory, error) {
+	var history []*db.ActiveHistory
+	if err := r.db.WithContext(ctx).Where("sku = ?", sku).Order("changed_at DESC").Find(&history).Error; err != nil {
+		r.log.Error("Failed to list active history", zap.String("sku", sku), zap.Error(err))
+		return nil, err
+	}
+	return history, nil
+}
+
 // getChangedFields compares old and new book and returns list of changed fields
 func (r *CatalogRepository) getChangedFields(old, new *db.Book, updateMask []string) []string {
 	var changed []string
@@ -0,0 +1,360 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"github.com/bookstore/services/catalog/pkg/logger"
+	"github.com/stretchr/testify/require"
+)
+
+// This file drives CatalogRepository as a state machine: random sequences
+// of Create/Get/Update/Delete/List/Search commands are executed against
+// both the real repository and a simple in-memory model, and every
+// postcondition the two must agree on is asserted after each step.
+//
+// SearchBooks relies on PostgreSQL's search_vector column, trigger, and
+// websearch_to_tsquery, which the sqlite used by the rest of this
+// package's tests can't run (see
+// TestSearchBooks), so this suite needs a real, disposable Postgres
+// database, named by CATALOG_PBT_POSTGRES_DSN; it's skipped otherwise.
+
+var (
+	pbtTitles     = []string{"Dune", "Foundation", "Neuromancer", "Snow Crash", "Hyperion", "Ubik"}
+	pbtAuthors    = []string{"Frank Herbert", "Isaac Asimov", "William Gibson", "Neal Stephenson", "Dan Simmons"}
+	pbtCategories = []string{"fiction", "scifi", "nonfiction", ""}
+)
+
+// pbtCommand is one step of a generated sequence. It is JSON-serializable
+// so a minimized failing sequence can be saved into the regression
+// corpus and replayed by a later run. RefIndex resolves to an actual SKU
+// at apply time (created[RefIndex % len(created)]) rather than embedding
+// a literal SKU, since the real SKUs (auto-generated by the repo) aren't
+// known until generation meets execution.
+type pbtCommand struct {
+	Kind           string   `json:"kind"`
+	SKU            string   `json:"sku,omitempty"`
+	RefIndex       int      `json:"ref_index,omitempty"`
+	Title          string   `json:"title,omitempty"`
+	Author         string   `json:"author,omitempty"`
+	Price          int64    `json:"price,omitempty"`
+	Category       string   `json:"category,omitempty"`
+	Active         bool     `json:"active,omitempty"`
+	UpdateMask     []string `json:"update_mask,omitempty"`
+	CategoryFilter string   `json:"category_filter,omitempty"`
+	ActiveOnly     bool     `json:"active_only,omitempty"`
+}
+
+// pbtState is the symbolic model: the set of books the repository should
+// contain, and the order they were created in (so RefIndex has something
+// stable to pick from).
+type pbtState struct {
+	model   map[string]*db.Book
+	created []string
+}
+
+func genCommand(rnd *rand.Rand) pbtCommand {
+	switch rnd.Intn(8) {
+	case 0:
+		return pbtCommand{
+			Kind:     "create",
+			Title:    pbtTitles[rnd.Intn(len(pbtTitles))],
+			Author:   pbtAuthors[rnd.Intn(len(pbtAuthors))],
+			Price:    int64(500 + rnd.Intn(9500)),
+			Category: pbtCategories[rnd.Intn(len(pbtCategories))],
+		}
+	case 1:
+		return pbtCommand{
+			Kind:     "create_duplicate",
+			RefIndex: rnd.Intn(1 << 30),
+			Title:    pbtTitles[rnd.Intn(len(pbtTitles))],
+			Author:   pbtAuthors[rnd.Intn(len(pbtAuthors))],
+			Price:    int64(500 + rnd.Intn(9500)),
+		}
+	case 2, 3:
+		return pbtCommand{Kind: "get_known", RefIndex: rnd.Intn(1 << 30)}
+	case 4:
+		return pbtCommand{Kind: "get_unknown", SKU: fmt.Sprintf("UNKNOWN-%04d", rnd.Intn(10000))}
+	case 5:
+		masks := [][]string{{"title"}, {"price"}, {"category"}, {"active"}, {"title", "price"}}
+		return pbtCommand{
+			Kind:       "update",
+			RefIndex:   rnd.Intn(1 << 30),
+			Title:      pbtTitles[rnd.Intn(len(pbtTitles))],
+			Price:      int64(500 + rnd.Intn(9500)),
+			Category:   pbtCategories[rnd.Intn(len(pbtCategories))],
+			Active:     rnd.Intn(2) == 0,
+			UpdateMask: masks[rnd.Intn(len(masks))],
+		}
+	case 6:
+		return pbtCommand{Kind: "delete", RefIndex: rnd.Intn(1 << 30)}
+	default:
+		if rnd.Intn(2) == 0 {
+			return pbtCommand{
+				Kind:           "list",
+				CategoryFilter: pbtCategories[rnd.Intn(len(pbtCategories))],
+				ActiveOnly:     rnd.Intn(2) == 0,
+			}
+		}
+		return pbtCommand{Kind: "search", RefIndex: rnd.Intn(1 << 30)}
+	}
+}
+
+func genCommandSequence(rnd *rand.Rand, n int) []pbtCommand {
+	seq := make([]pbtCommand, n)
+	for i := range seq {
+		seq[i] = genCommand(rnd)
+	}
+	return seq
+}
+
+func cloneBook(b *db.Book) *db.Book {
+	clone := *b
+	return &clone
+}
+
+// runPBTSequence applies seq to a freshly truncated repository and an
+// empty model in lockstep, using require so the first violated
+// postcondition fails the (sub)test immediately.
+func runPBTSequence(t *testing.T, dsn string, seq []pbtCommand) {
+	t.Helper()
+	ctx := context.Background()
+	database := pbtSetupDB(t, dsn)
+	log := logger.NewLogger("test", "error")
+	repository := NewCatalogRepository(database, log, nil)
+
+	state := &pbtState{model: make(map[string]*db.Book)}
+	lastAutoNum := 0
+
+	for _, cmd := range seq {
+		switch cmd.Kind {
+		case "create":
+			book := &db.Book{
+				Title: cmd.Title, Author: cmd.Author, Price: cmd.Price,
+				Currency: "USD", Category: cmd.Category, Active: true,
+			}
+			require.NoError(t, repository.CreateBook(ctx, book))
+
+			var n int
+			_, scanErr := fmt.Sscanf(book.SKU, "BOOK-%d", &n)
+			require.NoError(t, scanErr, "auto-generated SKU %q must match BOOK-%%03d", book.SKU)
+			require.Greater(t, n, lastAutoNum, "auto-generated SKUs must be strictly monotonic")
+			lastAutoNum = n
+
+			state.model[book.SKU] = cloneBook(book)
+			state.created = append(state.created, book.SKU)
+
+		case "create_duplicate":
+			if len(state.created) == 0 {
+				continue
+			}
+			sku := state.created[cmd.RefIndex%len(state.created)]
+			err := repository.CreateBook(ctx, &db.Book{
+				SKU: sku, Title: cmd.Title, Author: cmd.Author, Price: cmd.Price, Currency: "USD", Active: true,
+			})
+			require.ErrorIs(t, err, ErrBookAlreadyExists)
+
+		case "get_known":
+			if len(state.created) == 0 {
+				continue
+			}
+			sku := state.created[cmd.RefIndex%len(state.created)]
+			got, err := repository.GetBook(ctx, sku)
+			require.NoError(t, err)
+			require.Equal(t, state.model[sku].Title, got.Title)
+
+		case "get_unknown":
+			if _, exists := state.model[cmd.SKU]; exists {
+				continue // generator collided with a real SKU; not interesting
+			}
+			_, err := repository.GetBook(ctx, cmd.SKU)
+			require.ErrorIs(t, err, ErrBookNotFound)
+
+		case "update":
+			if len(state.created) == 0 {
+				continue
+			}
+			sku := state.created[cmd.RefIndex%len(state.created)]
+			updated := cloneBook(state.model[sku])
+			for _, field := range cmd.UpdateMask {
+				switch field {
+				case "title":
+					updated.Title = cmd.Title
+				case "price":
+					updated.Price = cmd.Price
+				case "category":
+					updated.Category = cmd.Category
+				case "active":
+					updated.Active = cmd.Active
+				}
+			}
+			_, err := repository.UpdateBook(ctx, updated, cmd.UpdateMask)
+			require.NoError(t, err)
+			state.model[sku] = updated
+
+		case "delete":
+			if len(state.created) == 0 {
+				continue
+			}
+			sku := state.created[cmd.RefIndex%len(state.created)]
+			require.NoError(t, repository.DeleteBook(ctx, sku))
+			state.model[sku].Active = false
+
+		case "list":
+			var expected int64
+			for _, b := range state.model {
+				if cmd.CategoryFilter != "" && b.Category != cmd.CategoryFilter {
+					continue
+				}
+				if cmd.ActiveOnly && !b.Active {
+					continue
+				}
+				expected++
+			}
+			_, total, err := repository.ListBooks(ctx, 1, 1000, cmd.CategoryFilter, "", cmd.ActiveOnly, 0, 0, 0, "", "")
+			require.NoError(t, err)
+			require.EqualValues(t, expected, total, "ListBooks(category=%q, activeOnly=%v)", cmd.CategoryFilter, cmd.ActiveOnly)
+
+		case "search":
+			if len(state.created) == 0 {
+				continue
+			}
+			sku := state.created[cmd.RefIndex%len(state.created)]
+			token := strings.Fields(state.model[sku].Title)[0]
+			_, total, err := repository.SearchBooks(ctx, token, 1, 1000, "", "")
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, total, int64(1), "searching %q must find at least %s, which it came from", token, sku)
+		}
+	}
+}
+
+func pbtSetupDB(t *testing.T, dsn string) *db.DB {
+	t.Helper()
+	database, err := db.Connect(db.Config{Driver: "postgres", PrimaryDSN: dsn})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	require.NoError(t, db.RunMigrations(database))
+	require.NoError(t, database.Exec("TRUNCATE books, outbox RESTART IDENTITY CASCADE").Error)
+	return database
+}
+
+// sequencePasses runs seq as a disposable subtest purely to get t.Run's
+// pass/fail verdict back, for use while shrinking.
+func sequencePasses(t *testing.T, dsn string, seq []pbtCommand) bool {
+	t.Helper()
+	return t.Run("shrink-check", func(t *testing.T) {
+		runPBTSequence(t, dsn, seq)
+	})
+}
+
+// shrinkSequence reduces a failing sequence to a smaller one that still
+// fails, first by halving from the end and then by dropping one command
+// at a time, until neither reduces it further.
+func shrinkSequence(t *testing.T, dsn string, seq []pbtCommand) []pbtCommand {
+	t.Helper()
+	current := append([]pbtCommand{}, seq...)
+
+	for {
+		reduced := false
+
+		for len(current) > 1 {
+			half := current[:len(current)/2]
+			if !sequencePasses(t, dsn, half) {
+				current = half
+				reduced = true
+			} else {
+				break
+			}
+		}
+
+		for i := 0; i < len(current); i++ {
+			candidate := make([]pbtCommand, 0, len(current)-1)
+			candidate = append(candidate, current[:i]...)
+			candidate = append(candidate, current[i+1:]...)
+			if len(candidate) == 0 {
+				continue
+			}
+			if !sequencePasses(t, dsn, candidate) {
+				current = candidate
+				reduced = true
+				i = -1
+			}
+		}
+
+		if !reduced {
+			return current
+		}
+	}
+}
+
+func loadPBTCorpus(t *testing.T, path string) [][]pbtCommand {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	require.NoError(t, err)
+
+	var corpus [][]pbtCommand
+	require.NoError(t, json.Unmarshal(data, &corpus))
+	return corpus
+}
+
+// appendToPBTCorpus persists a minimized failing sequence so future runs
+// replay it even if the random seed never regenerates it.
+func appendToPBTCorpus(t *testing.T, path string, seq []pbtCommand) {
+	t.Helper()
+	corpus := loadPBTCorpus(t, path)
+	corpus = append(corpus, seq)
+
+	data, err := json.MarshalIndent(corpus, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+}
+
+func TestCatalogRepositoryStateful(t *testing.T) {
+	dsn := os.Getenv("CATALOG_PBT_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("set CATALOG_PBT_POSTGRES_DSN to a scratch Postgres database to run the stateful property test; SearchBooks needs real to_tsvector support, which sqlite doesn't have")
+	}
+
+	corpusPath := filepath.Join("testdata", "catalog_pbt_corpus.json")
+
+	// Replay previously-minimized failures first, so a bug found once
+	// never silently starts passing again unnoticed.
+	for i, seq := range loadPBTCorpus(t, corpusPath) {
+		seq := seq
+		t.Run(fmt.Sprintf("corpus-%d", i), func(t *testing.T) {
+			runPBTSequence(t, dsn, seq)
+		})
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	const sequences = 30
+	for i := 0; i < sequences; i++ {
+		seq := genCommandSequence(rnd, 5+i)
+
+		ok := t.Run(fmt.Sprintf("random-%d", i), func(t *testing.T) {
+			runPBTSequence(t, dsn, seq)
+		})
+		if !ok {
+			minimal := shrinkSequence(t, dsn, seq)
+			appendToPBTCorpus(t, corpusPath, minimal)
+			t.Errorf("sequence %d failed; minimized reproducer (%d commands) saved to %s", i, len(minimal), corpusPath)
+		}
+	}
+}
@@ -14,11 +14,11 @@ import (
 )
 
 func setupTestDB(t *testing.T) *db.DB {
-	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	gormDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{TranslateError: true})
 	require.NoError(t, err)
 
 	// Run migrations
-	err = gormDB.AutoMigrate(&db.Book{})
+	err = gormDB.AutoMigrate(&db.Book{}, &db.Outbox{}, &db.Category{}, &db.BookCategory{}, &db.ActiveHistory{})
 	require.NoError(t, err)
 
 	return &db.DB{DB: gormDB}
@@ -27,7 +27,7 @@ func setupTestDB(t *testing.T) *db.DB {
 func TestCreateBook(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -51,12 +51,19 @@ func TestCreateBook(t *testing.T) {
 	assert.Equal(t, "Test Book", retrieved.Title)
 	assert.Equal(t, "Test Author", retrieved.Author)
 	assert.Equal(t, int64(1999), retrieved.Price)
+
+	// Verify the creation enqueued an outbox event, rather than publishing inline
+	batch, err := repo.ClaimOutboxBatch(ctx, 10, 5)
+	assert.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "TEST-001", batch[0].AggregateID)
+	assert.Equal(t, "catalog.created", batch[0].EventType)
 }
 
 func TestCreateBookDuplicate(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -82,7 +89,7 @@ func TestCreateBookDuplicate(t *testing.T) {
 func TestGetBook(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -95,7 +102,7 @@ func TestGetBook(t *testing.T) {
 func TestUpdateBook(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -129,7 +136,7 @@ func TestUpdateBook(t *testing.T) {
 func TestListBooks(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -147,24 +154,24 @@ func TestListBooks(t *testing.T) {
 	}
 
 	// Test pagination
-	result, total, err := repo.ListBooks(ctx, 1, 10, "", "", false, 0, 0)
+	result, total, err := repo.ListBooks(ctx, 1, 10, "", "", false, 0, 0, 0, "", "")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(3), total)
 	assert.Len(t, result, 3)
 
 	// Test category filter
-	result, total, err = repo.ListBooks(ctx, 1, 10, "fiction", "", false, 0, 0)
+	result, total, err = repo.ListBooks(ctx, 1, 10, "fiction", "", false, 0, 0, 0, "", "")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), total)
 	assert.Len(t, result, 2)
 
 	// Test active only filter
-	result, total, err = repo.ListBooks(ctx, 1, 10, "", "", true, 0, 0)
+	result, total, err = repo.ListBooks(ctx, 1, 10, "", "", true, 0, 0, 0, "", "")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), total)
 
 	// Test author filter
-	result, total, err = repo.ListBooks(ctx, 1, 10, "", "Author A", false, 0, 0)
+	result, total, err = repo.ListBooks(ctx, 1, 10, "", "Author A", false, 0, 0, 0, "", "")
 	assert.NoError(t, err)
 	assert.Equal(t, int64(2), total)
 }
@@ -172,7 +179,7 @@ func TestListBooks(t *testing.T) {
 func TestSearchBooks(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -190,7 +197,7 @@ func TestSearchBooks(t *testing.T) {
 	// Note: SQLite doesn't support PostgreSQL full-text search
 	// In a real test environment with PostgreSQL, this would work
 	// For SQLite, we'll just verify the function doesn't error
-	_, _, err := repo.SearchBooks(ctx, "Go", 1, 10, "")
+	_, _, err := repo.SearchBooks(ctx, "Go", 1, 10, "", "")
 	// SQLite may error on full-text search syntax, which is expected
 	// In production with PostgreSQL, this would return results
 	_ = err // Ignore error in this test
@@ -199,7 +206,7 @@ func TestSearchBooks(t *testing.T) {
 func TestDeleteBook(t *testing.T) {
 	database := setupTestDB(t)
 	log := logger.NewLogger("test", "info")
-	repo := NewCatalogRepository(database, log)
+	repo := NewCatalogRepository(database, log, nil)
 
 	ctx := context.Background()
 
@@ -223,6 +230,41 @@ func TestDeleteBook(t *testing.T) {
 	deleted, err := repo.GetBook(ctx, "TEST-004")
 	assert.NoError(t, err)
 	assert.False(t, deleted.Active)
+
+	// Verify the deletion left an audit trail
+	history, err := repo.ListActiveHistory(ctx, "TEST-004")
+	assert.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.True(t, history[0].FromActive)
+	assert.False(t, history[0].ToActive)
+	assert.Equal(t, "system", history[0].ChangedBy)
+}
+
+func TestToggleActive(t *testing.T) {
+	database := setupTestDB(t)
+	log := logger.NewLogger("test", "info")
+	repo := NewCatalogRepository(database, log, nil)
+
+	ctx := context.Background()
+
+	book := &db.Book{SKU: "TEST-005", Title: "Toggle Me", Author: "Test Author", Price: 1999, Currency: "USD", Active: true}
+	require.NoError(t, repo.CreateBook(ctx, book))
+
+	newActive, err := repo.ToggleActive(ctx, "TEST-005", "admin@example.com")
+	require.NoError(t, err)
+	assert.False(t, newActive)
+
+	newActive, err = repo.ToggleActive(ctx, "TEST-005", "admin@example.com")
+	require.NoError(t, err)
+	assert.True(t, newActive)
+
+	history, err := repo.ListActiveHistory(ctx, "TEST-005")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, "admin@example.com", history[0].ChangedBy)
+
+	_, err = repo.ToggleActive(ctx, "NONEXISTENT", "admin@example.com")
+	assert.Equal(t, ErrBookNotFound, err)
 }
 
 
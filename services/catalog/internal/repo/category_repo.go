@@ -0,0 +1,154 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCategoryNotFound is returned when a category is not found
+	ErrCategoryNotFound = errors.New("category not found")
+
+	// ErrCategorySlugExists is returned when creating a category whose
+	// slug collides with an existing one
+	ErrCategorySlugExists = errors.New("category slug already exists")
+)
+
+// CategoryStats is one row of GetCategoryStats: a category and how many
+// books are linked to it, split out by active state.
+type CategoryStats struct {
+	CategoryID uint   `json:"category_id"`
+	Name       string `json:"name"`
+	Slug       string `json:"slug"`
+	Total      int64  `json:"total"`
+	Active     int64  `json:"active"`
+}
+
+// CreateCategory creates a new taxonomy node. parentID may be nil for a
+// top-level category.
+func (r *CatalogRepository) CreateCategory(ctx context.Context, name, slug string, parentID *uint) (*db.Category, error) {
+	category := &db.Category{Name: name, Slug: slug, ParentID: parentID}
+
+	if err := r.db.WithContext(ctx).Create(category).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrCategorySlugExists
+		}
+		r.log.Error("Failed to create category", zap.String("slug", slug), zap.Error(err))
+		return nil, err
+	}
+
+	r.log.Info("Category created", zap.Uint("id", category.ID), zap.String("slug", slug))
+	return category, nil
+}
+
+// ListCategories returns every category. Parents are not guaranteed to
+// precede their children; a caller that needs the tree shape should
+// group the result by ParentID itself.
+func (r *CatalogRepository) ListCategories(ctx context.Context) ([]*db.Category, error) {
+	var categories []*db.Category
+	if err := r.db.WithContext(ctx).Order("name").Find(&categories).Error; err != nil {
+		r.log.Error("Failed to list categories", zap.Error(err))
+		return nil, err
+	}
+	return categories, nil
+}
+
+// AttachCategories links sku to each of categoryIDs, skipping any link
+// that already exists, and enqueues a category.attached event per
+// newly-created link in the same transaction (see CreateBook for why).
+func (r *CatalogRepository) AttachCategories(ctx context.Context, sku string, categoryIDs []uint) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, categoryID := range categoryIDs {
+			link := &db.BookCategory{BookSKU: sku, CategoryID: categoryID}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(link)
+			if result.Error != nil {
+				return fmt.Errorf("failed to attach category %d to %s: %w", categoryID, sku, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue // already attached
+			}
+			if err := enqueueOutbox(tx, sku, outboxEventCategoryAttached, map[string]interface{}{
+				"sku":         sku,
+				"category_id": categoryID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DetachCategories unlinks sku from each of categoryIDs and enqueues a
+// category.detached event per link actually removed.
+func (r *CatalogRepository) DetachCategories(ctx context.Context, sku string, categoryIDs []uint) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, categoryID := range categoryIDs {
+			result := tx.Where("book_sku = ? AND category_id = ?", sku, categoryID).Delete(&db.BookCategory{})
+			if result.Error != nil {
+				return fmt.Errorf("failed to detach category %d from %s: %w", categoryID, sku, result.Error)
+			}
+			if result.RowsAffected == 0 {
+				continue // was not attached
+			}
+			if err := enqueueOutbox(tx, sku, outboxEventCategoryDetached, map[string]interface{}{
+				"sku":         sku,
+				"category_id": categoryID,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetCategoryStats returns, for every category, how many books are
+// linked to it in total and how many of those are active.
+func (r *CatalogRepository) GetCategoryStats(ctx context.Context) ([]CategoryStats, error) {
+	categories, err := r.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]CategoryStats, 0, len(categories))
+	for _, category := range categories {
+		var total int64
+		if err := r.db.WithContext(ctx).Model(&db.Book{}).
+			Joins("JOIN book_categories ON book_categories.book_sku = books.sku").
+			Where("book_categories.category_id = ?", category.ID).
+			Count(&total).Error; err != nil {
+			return nil, fmt.Errorf("failed to count books for category %d: %w", category.ID, err)
+		}
+
+		var active int64
+		if err := r.db.WithContext(ctx).Model(&db.Book{}).
+			Joins("JOIN book_categories ON book_categories.book_sku = books.sku").
+			Where("book_categories.category_id = ? AND books.active = ?", category.ID, true).
+			Count(&active).Error; err != nil {
+			return nil, fmt.Errorf("failed to count active books for category %d: %w", category.ID, err)
+		}
+
+		stats = append(stats, CategoryStats{
+			CategoryID: category.ID,
+			Name:       category.Name,
+			Slug:       category.Slug,
+			Total:      total,
+			Active:     active,
+		})
+	}
+
+	return stats, nil
+}
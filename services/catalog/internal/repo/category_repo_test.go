@@ -0,0 +1,106 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"github.com/bookstore/services/catalog/pkg/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateCategory(t *testing.T) {
+	database := setupTestDB(t)
+	log := logger.NewLogger("test", "info")
+	repo := NewCatalogRepository(database, log, nil)
+
+	ctx := context.Background()
+
+	category, err := repo.CreateCategory(ctx, "Fiction", "fiction", nil)
+	require.NoError(t, err)
+	assert.NotZero(t, category.ID)
+	assert.Equal(t, "Fiction", category.Name)
+
+	_, err = repo.CreateCategory(ctx, "Fiction Again", "fiction", nil)
+	assert.ErrorIs(t, err, ErrCategorySlugExists)
+}
+
+func TestListCategories(t *testing.T) {
+	database := setupTestDB(t)
+	log := logger.NewLogger("test", "info")
+	repo := NewCatalogRepository(database, log, nil)
+
+	ctx := context.Background()
+
+	_, err := repo.CreateCategory(ctx, "Fiction", "fiction", nil)
+	require.NoError(t, err)
+	_, err = repo.CreateCategory(ctx, "Non-Fiction", "non-fiction", nil)
+	require.NoError(t, err)
+
+	categories, err := repo.ListCategories(ctx)
+	require.NoError(t, err)
+	assert.Len(t, categories, 2)
+}
+
+func TestAttachAndDetachCategories(t *testing.T) {
+	database := setupTestDB(t)
+	log := logger.NewLogger("test", "info")
+	repo := NewCatalogRepository(database, log, nil)
+
+	ctx := context.Background()
+
+	book := &db.Book{SKU: "TEST-20", Title: "Dune", Author: "Frank Herbert", Price: 1999, Currency: "USD", Active: true}
+	require.NoError(t, repo.CreateBook(ctx, book))
+
+	fiction, err := repo.CreateCategory(ctx, "Fiction", "fiction", nil)
+	require.NoError(t, err)
+	scifi, err := repo.CreateCategory(ctx, "Science Fiction", "science-fiction", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.AttachCategories(ctx, book.SKU, []uint{fiction.ID, scifi.ID}))
+
+	// Attaching the same category again is a no-op, not an error.
+	require.NoError(t, repo.AttachCategories(ctx, book.SKU, []uint{fiction.ID}))
+
+	books, total, err := repo.ListBooks(ctx, 1, 10, "", "", false, 0, 0, 0, "science-fiction", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, books, 1)
+	assert.Equal(t, book.SKU, books[0].SKU)
+
+	books, total, err = repo.ListBooks(ctx, 1, 10, "", "", false, 0, 0, fiction.ID, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, books, 1)
+
+	require.NoError(t, repo.DetachCategories(ctx, book.SKU, []uint{scifi.ID}))
+
+	_, total, err = repo.ListBooks(ctx, 1, 10, "", "", false, 0, 0, 0, "science-fiction", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestGetCategoryStats(t *testing.T) {
+	database := setupTestDB(t)
+	log := logger.NewLogger("test", "info")
+	repo := NewCatalogRepository(database, log, nil)
+
+	ctx := context.Background()
+
+	fiction, err := repo.CreateCategory(ctx, "Fiction", "fiction", nil)
+	require.NoError(t, err)
+
+	active := &db.Book{SKU: "TEST-21", Title: "Active Book", Author: "A", Price: 1000, Currency: "USD", Active: true}
+	inactive := &db.Book{SKU: "TEST-22", Title: "Inactive Book", Author: "B", Price: 1000, Currency: "USD", Active: false}
+	require.NoError(t, repo.CreateBook(ctx, active))
+	require.NoError(t, repo.CreateBook(ctx, inactive))
+	require.NoError(t, repo.AttachCategories(ctx, active.SKU, []uint{fiction.ID}))
+	require.NoError(t, repo.AttachCategories(ctx, inactive.SKU, []uint{fiction.ID}))
+
+	stats, err := repo.GetCategoryStats(ctx)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(2), stats[0].Total)
+	assert.Equal(t, int64(1), stats[0].Active)
+}
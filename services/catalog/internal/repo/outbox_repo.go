@@ -0,0 +1,209 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox event types. These mirror the routing keys events.Publisher
+// uses (events.EventTypeCatalogCreated/Updated/Deleted) so the dispatcher
+// can hand a row's payload straight to the broker without this package
+// depending on the events package.
+const (
+	outboxEventCatalogCreated   = "catalog.created"
+	outboxEventCatalogUpdated   = "catalog.updated"
+	outboxEventCatalogDeleted   = "catalog.deleted"
+	outboxEventCategoryAttached = "category.attached"
+	outboxEventCategoryDetached = "category.detached"
+	outboxEventBookActivated    = "book.activated"
+	outboxEventBookDeactivated  = "book.deactivated"
+)
+
+// outboxBackoffBase and outboxBackoffMax bound the exponential backoff
+// MarkOutboxFailed applies between retries: base * 2^attempts, capped at
+// max, so a row failing repeatedly backs off instead of being reclaimed
+// every poll interval.
+const (
+	outboxBackoffBase = 2 * time.Second
+	outboxBackoffMax  = 5 * time.Minute
+)
+
+// OutboxRow is a row claimed from the outbox table by the dispatcher for
+// delivery.
+type OutboxRow struct {
+	ID          string
+	AggregateID string
+	EventType   string
+	Payload     json.RawMessage
+	Attempts    int32
+}
+
+// enqueueOutbox inserts an event row into the outbox using tx, so it
+// commits atomically with whatever mutation produced it.
+func enqueueOutbox(tx *gorm.DB, aggregateID, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	row := &db.Outbox{
+		ID:          uuid.New().String(),
+		AggregateID: aggregateID,
+		EventType:   eventType,
+		Payload:     body,
+	}
+	if err := tx.Create(row).Error; err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	return nil
+}
+
+// Transaction runs fn in a single transaction against the primary. The
+// outbox dispatcher uses it to hold ClaimOutboxBatch's SKIP LOCKED lock
+// on a claimed batch for as long as it takes to publish and mark every
+// row in it, instead of the lock being released the instant the claim's
+// own SELECT returns and leaving the batch unprotected against a second
+// dispatcher replica claiming the same rows.
+func (r *CatalogRepository) Transaction(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return db.WithPrimary(r.db.WithContext(ctx)).Transaction(fn)
+}
+
+// ClaimOutboxBatch locks up to limit unpublished rows that have not yet
+// exhausted maxAttempts, skipping rows a concurrent dispatcher already
+// holds. Rows are ordered by (aggregate_id, created_at, id) so a single
+// dispatcher attempts events for the same aggregate in the order they
+// were raised.
+func (r *CatalogRepository) ClaimOutboxBatch(ctx context.Context, limit int, maxAttempts int32) ([]OutboxRow, error) {
+	return r.ClaimOutboxBatchTx(db.WithPrimary(r.db.WithContext(ctx)), limit, maxAttempts)
+}
+
+// ClaimOutboxBatchTx is ClaimOutboxBatch run against an already-open tx
+// (see Transaction), so the lock it takes via SKIP LOCKED is held until
+// tx commits rather than released as soon as this query returns.
+func (r *CatalogRepository) ClaimOutboxBatchTx(tx *gorm.DB, limit int, maxAttempts int32) ([]OutboxRow, error) {
+	var rows []db.Outbox
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("published_at IS NULL AND attempts < ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", maxAttempts, time.Now()).
+		Order("aggregate_id, created_at, id").
+		Limit(limit).
+		Find(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox batch: %w", err)
+	}
+
+	batch := make([]OutboxRow, 0, len(rows))
+	for _, row := range rows {
+		batch = append(batch, OutboxRow{
+			ID:          row.ID,
+			AggregateID: row.AggregateID,
+			EventType:   row.EventType,
+			Payload:     row.Payload,
+			Attempts:    row.Attempts,
+		})
+	}
+	return batch, nil
+}
+
+// MarkOutboxPublished records that row id was delivered successfully.
+func (r *CatalogRepository) MarkOutboxPublished(ctx context.Context, id string) error {
+	return r.MarkOutboxPublishedTx(db.WithPrimary(r.db.WithContext(ctx)), id)
+}
+
+// MarkOutboxPublishedTx is MarkOutboxPublished run against an
+// already-open tx; see ClaimOutboxBatchTx.
+func (r *CatalogRepository) MarkOutboxPublishedTx(tx *gorm.DB, id string) error {
+	now := time.Now()
+	err := tx.Model(&db.Outbox{}).Where("id = ?", id).
+		Update("published_at", &now).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox row %s published: %w", id, err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed increments the attempt counter for row id after a
+// failed publish and schedules its next retry with exponential backoff:
+// outboxBackoffBase * 2^attempts, where attempts is the row's count
+// before this failure (the dispatcher passes row.Attempts), capped at
+// outboxBackoffMax.
+func (r *CatalogRepository) MarkOutboxFailed(ctx context.Context, id string, attempts int32) error {
+	return r.MarkOutboxFailedTx(db.WithPrimary(r.db.WithContext(ctx)), id, attempts)
+}
+
+// MarkOutboxFailedTx is MarkOutboxFailed run against an already-open tx;
+// see ClaimOutboxBatchTx.
+func (r *CatalogRepository) MarkOutboxFailedTx(tx *gorm.DB, id string, attempts int32) error {
+	backoff := outboxBackoffBase * time.Duration(1<<attempts)
+	if backoff > outboxBackoffMax || backoff <= 0 {
+		backoff = outboxBackoffMax
+	}
+	nextAttempt := time.Now().Add(backoff)
+
+	err := tx.Model(&db.Outbox{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": nextAttempt,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox row %s failed: %w", id, err)
+	}
+	return nil
+}
+
+// DeadLetterOutbox moves a row that exhausted its retry budget into
+// outbox_dead_letters for manual inspection and removes it from the
+// active outbox.
+func (r *CatalogRepository) DeadLetterOutbox(ctx context.Context, row OutboxRow) error {
+	return db.WithPrimary(r.db.WithContext(ctx)).Transaction(func(tx *gorm.DB) error {
+		return r.DeadLetterOutboxTx(tx, row)
+	})
+}
+
+// DeadLetterOutboxTx is DeadLetterOutbox run against an already-open tx
+// (see ClaimOutboxBatchTx) — important here specifically, since a fresh
+// transaction on a separate connection would block on the very row lock
+// the caller is already holding from ClaimOutboxBatchTx, deadlocking the
+// drain instead of completing it.
+func (r *CatalogRepository) DeadLetterOutboxTx(tx *gorm.DB, row OutboxRow) error {
+	deadLetter := &db.OutboxDeadLetter{
+		ID:          uuid.New().String(),
+		OutboxID:    row.ID,
+		AggregateID: row.AggregateID,
+		EventType:   row.EventType,
+		Payload:     row.Payload,
+		Attempts:    row.Attempts,
+	}
+	if err := tx.Create(deadLetter).Error; err != nil {
+		return fmt.Errorf("failed to dead-letter outbox row %s: %w", row.ID, err)
+	}
+	if err := tx.Where("id = ?", row.ID).Delete(&db.Outbox{}).Error; err != nil {
+		return fmt.Errorf("failed to remove dead-lettered outbox row %s: %w", row.ID, err)
+	}
+	return nil
+}
+
+// OldestUnpublishedOutboxAge reports how long the oldest unpublished
+// outbox row has been waiting, for the dispatcher's lag gauge. It
+// returns zero when the outbox is empty.
+func (r *CatalogRepository) OldestUnpublishedOutboxAge(ctx context.Context) (time.Duration, error) {
+	var oldest sql.NullTime
+	err := r.db.WithContext(ctx).Model(&db.Outbox{}).
+		Where("published_at IS NULL").
+		Select("MIN(created_at)").
+		Scan(&oldest).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to read oldest unpublished outbox row: %w", err)
+	}
+	if !oldest.Valid {
+		return 0, nil
+	}
+	return time.Since(oldest.Time), nil
+}
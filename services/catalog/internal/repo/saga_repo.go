@@ -0,0 +1,115 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrSagaNotFound is returned when a saga instance does not exist.
+var ErrSagaNotFound = errors.New("saga not found")
+
+// SagaRepository persists saga.Coordinator's instances and step log so
+// an in-flight saga survives a restart and can be resumed or audited.
+type SagaRepository struct {
+	db  *db.DB
+	log *zap.Logger
+}
+
+// NewSagaRepository creates a new saga repository
+func NewSagaRepository(database *db.DB, logger *zap.Logger) *SagaRepository {
+	return &SagaRepository{db: database, log: logger}
+}
+
+// CreateInstance records a new saga, starting at firstStep.
+func (r *SagaRepository) CreateInstance(ctx context.Context, id, name, firstStep string, payload []byte, state string) error {
+	instance := &db.SagaInstance{
+		ID:          id,
+		Name:        name,
+		State:       state,
+		CurrentStep: firstStep,
+		Payload:     payload,
+	}
+	return db.WithPrimary(r.db.WithContext(ctx)).Create(instance).Error
+}
+
+// UpdateInstance persists the saga's new state, current step, and
+// (possibly step-enriched) payload.
+func (r *SagaRepository) UpdateInstance(ctx context.Context, id, state, currentStep string, payload []byte) error {
+	return db.WithPrimary(r.db.WithContext(ctx)).
+		Model(&db.SagaInstance{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"state":        state,
+			"current_step": currentStep,
+			"payload":      payload,
+		}).Error
+}
+
+// GetInstance returns the saga instance for id, or ErrSagaNotFound.
+func (r *SagaRepository) GetInstance(ctx context.Context, id string) (*db.SagaInstance, error) {
+	var instance db.SagaInstance
+	err := r.db.WithContext(ctx).Where("id = ?", id).First(&instance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSagaNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// InFlightInstances returns every saga not yet in a terminal state, for
+// Coordinator.Recover to resume on startup.
+func (r *SagaRepository) InFlightInstances(ctx context.Context, terminalStates []string) ([]db.SagaInstance, error) {
+	var instances []db.SagaInstance
+	err := db.WithPrimary(r.db.WithContext(ctx)).
+		Where("state NOT IN ?", terminalStates).
+		Find(&instances).Error
+	return instances, err
+}
+
+// AppendStepLog records one attempt at a step (or its compensation).
+func (r *SagaRepository) AppendStepLog(ctx context.Context, sagaID, step, status string, attempt int, errMsg string) error {
+	entry := &db.SagaStepLog{
+		SagaID:  sagaID,
+		Step:    step,
+		Status:  status,
+		Attempt: attempt,
+		Error:   errMsg,
+	}
+	return db.WithPrimary(r.db.WithContext(ctx)).Create(entry).Error
+}
+
+// StepSucceeded reports whether step previously logged a "succeeded"
+// entry for sagaID, so a resumed saga skips steps it already completed
+// instead of re-running a non-idempotent side effect twice.
+func (r *SagaRepository) StepSucceeded(ctx context.Context, sagaID, step string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&db.SagaStepLog{}).
+		Where("saga_id = ? AND step = ? AND status = ?", sagaID, step, "succeeded").
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CompletedSteps returns the names of every step that succeeded for
+// sagaID, most recent first. The Coordinator's compensation walk does
+// not call it; it walks the Def's steps instead.
+func (r *SagaRepository) CompletedSteps(ctx context.Context, sagaID string) ([]string, error) {
+	var logs []db.SagaStepLog
+	err := r.db.WithContext(ctx).
+		Where("saga_id = ? AND status = ?", sagaID, "succeeded").
+		Order("id DESC").
+		Find(&logs).Error
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]string, 0, len(logs))
+	for _, l := range logs {
+		steps = append(steps, l.Step)
+	}
+	return steps, nil
+}
@@ -0,0 +1,98 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bookstore/services/catalog/internal/clients"
+	"github.com/bookstore/services/catalog/internal/events"
+)
+
+// OrderFulfillmentDefName is the Def.Name used by NewOrderFulfillmentDef,
+// and the value persisted on every SagaInstance it drives.
+const OrderFulfillmentDefName = "order_fulfillment"
+
+// OrderFulfillmentPayload is the payload threaded through an
+// order_fulfillment saga's steps.
+type OrderFulfillmentPayload struct {
+	OrderID string                 `json:"order_id"`
+	Items   []clients.ReservedItem `json:"items"`
+}
+
+// NewOrderFulfillmentDef builds the order_fulfillment saga: reserve the
+// order's stock, then publish an order.confirmed event; if publishing
+// fails after stock was reserved, the reservation is released.
+//
+// There is no catalog RPC that starts this saga yet — ReserveStock and
+// ReleaseStock are invoked synchronously via inventoryClient here as a
+// second, RPC-driven path alongside inventory's own event-driven saga
+// (internal/saga in the inventory service), which reacts to
+// order.created/confirmed/cancelled instead. Wiring a real trigger
+// (e.g. a CreateOrder RPC) is left for whichever request adds one.
+func NewOrderFulfillmentDef(inventoryClient *clients.InventoryClient, publisher *events.Publisher) Def {
+	return Def{
+		Name: OrderFulfillmentDefName,
+		Steps: []Step{
+			{
+				Name:       "reserve_stock",
+				Forward:    reserveStockStep(inventoryClient),
+				Compensate: releaseStockStep(inventoryClient),
+			},
+			{
+				Name:    "publish_confirmation",
+				Forward: publishConfirmationStep(publisher),
+			},
+		},
+	}
+}
+
+func reserveStockStep(inventoryClient *clients.InventoryClient) StepFunc {
+	return func(ctx context.Context, sagaID, step string, payload json.RawMessage) (json.RawMessage, error) {
+		var p OrderFulfillmentPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, fmt.Errorf("failed to decode saga %s payload: %w", sagaID, err)
+		}
+
+		success, message, err := inventoryClient.ReserveStock(ctx, p.OrderID, p.Items)
+		if err != nil {
+			return nil, err
+		}
+		if !success {
+			return nil, fmt.Errorf("inventory declined reservation for order %s: %s", p.OrderID, message)
+		}
+		return payload, nil
+	}
+}
+
+func releaseStockStep(inventoryClient *clients.InventoryClient) CompensateFunc {
+	return func(ctx context.Context, sagaID, step string, payload json.RawMessage) error {
+		var p OrderFulfillmentPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return fmt.Errorf("failed to decode saga %s payload: %w", sagaID, err)
+		}
+
+		success, message, err := inventoryClient.ReleaseStock(ctx, p.OrderID, p.Items)
+		if err != nil {
+			return err
+		}
+		if !success {
+			return fmt.Errorf("inventory declined release for order %s: %s", p.OrderID, message)
+		}
+		return nil
+	}
+}
+
+func publishConfirmationStep(publisher *events.Publisher) StepFunc {
+	return func(ctx context.Context, sagaID, step string, payload json.RawMessage) (json.RawMessage, error) {
+		var p OrderFulfillmentPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return nil, fmt.Errorf("failed to decode saga %s payload: %w", sagaID, err)
+		}
+
+		if err := publisher.PublishOrderConfirmed(ctx, p.OrderID); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	}
+}
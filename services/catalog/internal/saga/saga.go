@@ -0,0 +1,264 @@
+// Package saga provides a generic, step-based orchestrator for
+// multi-service transactions that must either fully commit or be
+// compensated, such as reserving inventory stock for an order and
+// confirming or releasing it once the order outcome is known.
+//
+// A Def is an ordered list of Steps, each with a Forward action and an
+// optional Compensate action. Coordinator.Start executes a Def's steps
+// in order, persisting the saga's state and an append-only step log
+// after every transition via repo.SagaRepository. A step whose Forward
+// returns an error is retried with exponential backoff up to
+// MaxAttempts; once exhausted, the saga walks the Def's steps before the
+// failed one in reverse, invoking Compensate for each of them, since
+// every one of them succeeded for the saga to reach the failed step.
+//
+// Every Forward/Compensate call is handed the saga ID and step name so
+// handlers can dedupe retries and safely resume after a crash: Start
+// skips any step already logged as succeeded, which is what makes
+// Coordinator.Recover safe to call for an in-flight saga at startup.
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/bookstore/services/catalog/internal/db"
+	"github.com/bookstore/services/catalog/internal/repo"
+	"go.uber.org/zap"
+)
+
+// State is the lifecycle state of a saga instance.
+type State string
+
+const (
+	StateRunning      State = "running"
+	StateCompleted    State = "completed"
+	StateCompensating State = "compensating"
+	StateFailed       State = "failed"
+)
+
+// terminalStates lists the states Recover should not resume.
+var terminalStates = []string{string(StateCompleted), string(StateFailed)}
+
+// DefaultMaxAttempts is how many times a step's Forward is attempted
+// before the saga gives up and compensates, when Step.MaxAttempts is 0.
+const DefaultMaxAttempts = 3
+
+// initialBackoff and maxBackoff bound the exponential backoff between
+// retries of a single step, mirroring events.rabbitBroker's retry loop.
+const (
+	initialBackoff = 200 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// StepFunc performs one saga step's forward action. sagaID and step
+// identify the call so handlers can dedupe a retried or resumed
+// attempt; payload is the saga's current accumulated state, and the
+// returned []byte becomes the new payload for subsequent steps.
+type StepFunc func(ctx context.Context, sagaID, step string, payload json.RawMessage) (json.RawMessage, error)
+
+// CompensateFunc undoes a step that previously succeeded. A nil
+// CompensateFunc means the step has nothing to undo.
+type CompensateFunc func(ctx context.Context, sagaID, step string, payload json.RawMessage) error
+
+// Step is one forward action and its compensation in a Def.
+type Step struct {
+	Name        string
+	Forward     StepFunc
+	Compensate  CompensateFunc
+	MaxAttempts int // 0 => DefaultMaxAttempts
+}
+
+// Def is an ordered saga definition. Name must be stable across
+// deploys: it is persisted on every instance and used by Recover to
+// look the Def back up for an in-flight saga found in the database.
+type Def struct {
+	Name  string
+	Steps []Step
+}
+
+// Coordinator drives Defs registered with it, persisting every
+// transition through a SagaRepository so in-flight sagas survive a
+// restart.
+type Coordinator struct {
+	repo     *repo.SagaRepository
+	log      *zap.Logger
+	registry map[string]Def
+}
+
+// NewCoordinator returns a Coordinator with an empty Def registry;
+// call Register for every Def that Start or Recover may need to run.
+func NewCoordinator(sagaRepo *repo.SagaRepository, log *zap.Logger) *Coordinator {
+	return &Coordinator{repo: sagaRepo, log: log, registry: make(map[string]Def)}
+}
+
+// Register makes def resumable by Recover. Start also registers its
+// Def automatically, so calling Register upfront is only required for
+// Defs that must be recoverable before their first Start.
+func (c *Coordinator) Register(def Def) {
+	c.registry[def.Name] = def
+}
+
+// Start begins executing def for sagaID with the given initial
+// payload, persisting state after every step, and returns once the
+// saga reaches a terminal state (completed or failed-and-compensated).
+func (c *Coordinator) Start(ctx context.Context, def Def, sagaID string, payload json.RawMessage) error {
+	if len(def.Steps) == 0 {
+		return fmt.Errorf("saga %q has no steps", def.Name)
+	}
+	c.Register(def)
+
+	if err := c.repo.CreateInstance(ctx, sagaID, def.Name, def.Steps[0].Name, payload, string(StateRunning)); err != nil {
+		return fmt.Errorf("failed to start saga %s: %w", sagaID, err)
+	}
+
+	return c.run(ctx, def, sagaID, payload, 0)
+}
+
+// Recover rehydrates every non-terminal saga instance from the
+// database and resumes it from its first not-yet-succeeded step. Call
+// this once at startup, after registering every Def the service runs.
+func (c *Coordinator) Recover(ctx context.Context) error {
+	instances, err := c.repo.InFlightInstances(ctx, terminalStates)
+	if err != nil {
+		return fmt.Errorf("failed to list in-flight sagas: %w", err)
+	}
+
+	for _, instance := range instances {
+		def, ok := c.registry[instance.Name]
+		if !ok {
+			c.log.Warn("Skipping in-flight saga with unregistered definition",
+				zap.String("saga_id", instance.ID), zap.String("name", instance.Name))
+			continue
+		}
+
+		fromIndex := stepIndex(def, instance.CurrentStep)
+		if fromIndex < 0 {
+			fromIndex = 0
+		}
+		c.log.Info("Resuming in-flight saga",
+			zap.String("saga_id", instance.ID), zap.String("name", instance.Name),
+			zap.String("from_step", def.Steps[fromIndex].Name))
+
+		if err := c.run(ctx, def, instance.ID, instance.Payload, fromIndex); err != nil {
+			c.log.Error("Resumed saga ended in failure",
+				zap.String("saga_id", instance.ID), zap.Error(err))
+		}
+	}
+	return nil
+}
+
+func stepIndex(def Def, name string) int {
+	for i, step := range def.Steps {
+		if step.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Coordinator) run(ctx context.Context, def Def, sagaID string, payload json.RawMessage, fromIndex int) error {
+	for i := fromIndex; i < len(def.Steps); i++ {
+		step := def.Steps[i]
+
+		if done, err := c.repo.StepSucceeded(ctx, sagaID, step.Name); err != nil {
+			return fmt.Errorf("failed to check saga %s step %s: %w", sagaID, step.Name, err)
+		} else if done {
+			continue
+		}
+
+		out, err := c.runStepWithRetry(ctx, step, sagaID, payload)
+		if err != nil {
+			c.log.Error("Saga step failed, compensating",
+				zap.String("saga_id", sagaID), zap.String("step", step.Name), zap.Error(err))
+			if updErr := c.repo.UpdateInstance(ctx, sagaID, string(StateCompensating), step.Name, payload); updErr != nil {
+				c.log.Error("Failed to mark saga compensating", zap.String("saga_id", sagaID), zap.Error(updErr))
+			}
+			c.compensate(ctx, def, sagaID, payload, i-1)
+			if updErr := c.repo.UpdateInstance(ctx, sagaID, string(StateFailed), step.Name, payload); updErr != nil {
+				c.log.Error("Failed to mark saga failed", zap.String("saga_id", sagaID), zap.Error(updErr))
+			}
+			return fmt.Errorf("saga %s step %s failed: %w", sagaID, step.Name, err)
+		}
+
+		payload = out
+		nextStep := ""
+		if i+1 < len(def.Steps) {
+			nextStep = def.Steps[i+1].Name
+		}
+		if err := c.repo.UpdateInstance(ctx, sagaID, string(StateRunning), nextStep, payload); err != nil {
+			c.log.Error("Failed to persist saga progress", zap.String("saga_id", sagaID), zap.Error(err))
+		}
+	}
+
+	return c.repo.UpdateInstance(ctx, sagaID, string(StateCompleted), "", payload)
+}
+
+func (c *Coordinator) runStepWithRetry(ctx context.Context, step Step, sagaID string, payload json.RawMessage) (json.RawMessage, error) {
+	maxAttempts := step.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+
+	backoff := initialBackoff
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+				backoff *= 2
+				if backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+			}
+		}
+
+		out, err := step.Forward(ctx, sagaID, step.Name, payload)
+		if err == nil {
+			if logErr := c.repo.AppendStepLog(ctx, sagaID, step.Name, "succeeded", attempt, ""); logErr != nil {
+				c.log.Error("Failed to append saga step log", zap.String("saga_id", sagaID), zap.Error(logErr))
+			}
+			return out, nil
+		}
+
+		lastErr = err
+		if logErr := c.repo.AppendStepLog(ctx, sagaID, step.Name, "failed", attempt, err.Error()); logErr != nil {
+			c.log.Error("Failed to append saga step log", zap.String("saga_id", sagaID), zap.Error(logErr))
+		}
+	}
+
+	return nil, fmt.Errorf("step %s exhausted %d attempts: %w", step.Name, maxAttempts, lastErr)
+}
+
+// compensate walks def's steps in reverse from uptoIndex, invoking
+// Compensate for each; it is best-effort and keeps going even if one
+// compensation fails, logging rather than aborting the walk.
+func (c *Coordinator) compensate(ctx context.Context, def Def, sagaID string, payload json.RawMessage, uptoIndex int) {
+	for i := uptoIndex; i >= 0; i-- {
+		step := def.Steps[i]
+		if step.Compensate == nil {
+			continue
+		}
+
+		if err := step.Compensate(ctx, sagaID, step.Name, payload); err != nil {
+			c.log.Error("Saga compensation failed",
+				zap.String("saga_id", sagaID), zap.String("step", step.Name), zap.Error(err))
+			if logErr := c.repo.AppendStepLog(ctx, sagaID, step.Name, "compensate_failed", 1, err.Error()); logErr != nil {
+				c.log.Error("Failed to append saga step log", zap.String("saga_id", sagaID), zap.Error(logErr))
+			}
+			continue
+		}
+		if logErr := c.repo.AppendStepLog(ctx, sagaID, step.Name, "compensated", 1, ""); logErr != nil {
+			c.log.Error("Failed to append saga step log", zap.String("saga_id", sagaID), zap.Error(logErr))
+		}
+	}
+}
+
+// GetStatus returns the current instance for sagaID.
+func (c *Coordinator) GetStatus(ctx context.Context, sagaID string) (*db.SagaInstance, error) {
+	return c.repo.GetInstance(ctx, sagaID)
+}
@@ -7,6 +7,17 @@ import (
 
 // NewLogger creates a new structured logger with the given service name and log level
 func NewLogger(serviceName, logLevel string) *zap.Logger {
+	logger, _ := NewAtomicLogger(serviceName, logLevel)
+	return logger
+}
+
+// NewAtomicLogger is NewLogger, but also returns the zap.AtomicLevel
+// backing the logger's verbosity. zap.AtomicLevel implements
+// http.Handler: GET returns the current level, PUT {"level":"debug"}
+// changes it, so callers that need to flip verbosity at runtime (see
+// cmd/catalogd/main.go's admin endpoint) can mount the level directly
+// on a ServeMux instead of reaching back into the logger's internals.
+func NewAtomicLogger(serviceName, logLevel string) (*zap.Logger, zap.AtomicLevel) {
 	config := zap.NewProductionConfig()
 
 	// Set log level
@@ -44,9 +55,5 @@ func NewLogger(serviceName, logLevel string) *zap.Logger {
 		panic(err)
 	}
 
-	return logger
+	return logger, config.Level
 }
-
-
-
-
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -13,32 +16,96 @@ import (
 	"github.com/bookstore/inventory/internal/db"
 	"github.com/bookstore/inventory/internal/events"
 	"github.com/bookstore/inventory/internal/grpc"
+	"github.com/bookstore/inventory/internal/health"
 	"github.com/bookstore/inventory/internal/repo"
+	"github.com/bookstore/inventory/internal/saga"
+	wstransport "github.com/bookstore/inventory/internal/transport/websocket"
 	"github.com/bookstore/inventory/pkg/logger"
+	"github.com/bookstore/inventory/pkg/observability"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
-	// Initialize logger
-	log := logger.New("inventory")
-
 	// Load configuration
 	cfg := config.Load()
+
+	// Initialize logger
+	log, logLevel := logger.New("inventory", cfg.LogLevel)
 	log.Info("Starting Inventory Service", "port", cfg.GRPCPort)
 
-	// Connect to database
-	database, err := db.Connect(cfg.PGDSN)
+	// Initialize OpenTelemetry tracing; every gRPC and AMQP call made
+	// from here on is instrumented without further plumbing.
+	shutdownTracing, err := observability.Init(cfg.ServiceName)
+	if err != nil {
+		log.Warn("Failed to initialize OpenTelemetry, continuing without tracing", "error", err)
+		shutdownTracing = nil
+	}
+	if shutdownTracing != nil {
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Error("Failed to shut down tracing", "error", err)
+			}
+		}()
+	}
+
+	// Start the health monitor and the HTTP server that exposes it before
+	// doing anything else that can block or fail (DB connect, migrations,
+	// broker connect): /startupz reports 503 until MarkStarted is called
+	// below, so a k8s startup probe can poll it from the moment the pod's
+	// port opens instead of the port not existing until migrations finish.
+	monitor := health.NewMonitor(cfg.HealthFailureThreshold)
+	httpMux := http.NewServeMux()
+	httpMux.HandleFunc("/livez", livezHandler())
+	httpMux.HandleFunc("/readyz", readyHandler(monitor))
+	httpMux.HandleFunc("/startupz", startupHandler(monitor))
+	httpMux.Handle("/metrics", promhttp.Handler())
+	httpMux.Handle("/admin/loglevel", requireAdminToken(cfg.AdminToken, logLevel))
+
+	httpServer := &http.Server{
+		Addr:         fmt.Sprintf(":%s", cfg.HTTPHealthPort),
+		Handler:      httpMux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info("Health check server listening", "addr", httpServer.Addr)
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("Health server error", "error", err)
+		}
+	}()
+
+	// Connect to database: primary plus any configured read replicas
+	primaryDB, replicaDBs, err := db.Connect(db.Config{
+		Driver:      cfg.DBDriver,
+		PrimaryDSN:  cfg.PGDSN,
+		ReplicaDSNs: cfg.DBReplicaDSNs,
+	})
 	if err != nil {
 		log.Fatal("Failed to connect to database", "error", err)
 	}
-	defer database.Close()
+	defer primaryDB.Close()
+	for _, replica := range replicaDBs {
+		defer replica.Close()
+	}
 
-	// Run migrations
-	if err := db.RunMigrations(database); err != nil {
+	// Run migrations against the primary; replicas never receive schema changes.
+	if err := db.RunMigrations(primaryDB); err != nil {
 		log.Fatal("Failed to run migrations", "error", err)
 	}
 
+	// Export DB pool stats (sqlDB.Stats()) to /metrics for each pool member.
+	statsCtx, stopStatsCollectors := context.WithCancel(context.Background())
+	defer stopStatsCollectors()
+	go observability.RunDBStatsCollector(statsCtx, "primary", primaryDB, 15*time.Second)
+	for i, replica := range replicaDBs {
+		go observability.RunDBStatsCollector(statsCtx, fmt.Sprintf("replica-%d", i), replica, 15*time.Second)
+	}
+
 	// Initialize repository
-	repository := repo.NewInventoryRepo(database)
+	repository := repo.NewInventoryRepo(primaryDB, replicaDBs...)
 
 	// Initialize event publisher
 	publisher, err := events.NewPublisher(cfg.RabbitMQURL, cfg.ServiceName)
@@ -49,12 +116,41 @@ func main() {
 		log.Info("Event publisher initialized")
 	}
 
+	// Fan every event this service publishes or consumes out to
+	// in-process WebSocket subscribers too, so clients get live stock
+	// updates without polling gRPC or standing up their own RabbitMQ
+	// consumer.
+	eventHub := events.NewEventHub()
+	if publisher != nil {
+		publisher.SetHub(eventHub)
+	}
+	httpMux.Handle("/ws/events", wstransport.NewHandler(eventHub, cfg.WSToken))
+
+	// The saga coordinator drives the order/inventory reservation saga:
+	// order.created reserves stock, order.confirmed commits it,
+	// order.cancelled (or a reservation timing out unconfirmed) releases
+	// it.
+	//
+	// Known gap, not yet resolved: coordinator.GetStatus has no gRPC
+	// surface. It should back a SagaService.GetStatus RPC for debugging,
+	// the same way every other gRPC service in this repo is generated
+	// from its own contracts/gen/go/* package, but no saga.proto exists
+	// in contracts for it to be generated from. Add one and wire
+	// RegisterSagaServiceServer alongside RegisterInventoryServiceServer
+	// below once it does; until then GetStatus is reachable only
+	// in-process.
+	coordinator := saga.NewCoordinator(repository, cfg.SagaReservationTimeout)
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	go coordinator.RunTimeoutReaper(reaperCtx, 30*time.Second)
+
 	// Initialize event consumer
-	consumer, err := events.NewConsumer(cfg.RabbitMQURL, cfg.ServiceName, repository, publisher)
+	consumer, err := events.NewConsumer(cfg.RabbitMQURL, cfg.ServiceName, repository, publisher, coordinator, events.DefaultRetryPolicy())
 	if err != nil {
 		log.Warn("Failed to initialize event consumer", "error", err)
 	} else {
 		defer consumer.Close()
+		consumer.SetHub(eventHub)
 
 		// Start consuming events
 		go func() {
@@ -65,11 +161,54 @@ func main() {
 		log.Info("Event consumer started")
 	}
 
-	// Initialize gRPC server
-	grpcServer := grpc.NewServer(repository, publisher)
+	// Dead-letter inspection/replay for messages the consumer gave up on
+	// after exhausting its RetryPolicy. Gated behind the same admin token
+	// as /admin/loglevel.
+	httpMux.Handle("GET /admin/dlq", requireAdminToken(cfg.AdminToken, dlqListHandler(repository)))
+	httpMux.Handle("POST /admin/dlq/{id}/replay", requireAdminToken(cfg.AdminToken, dlqReplayHandler(consumer)))
+
+	// Start the outbox dispatcher, which drains events written to the
+	// outbox table by repo.InventoryRepo's mutations into the broker.
+	dispatcherCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	if publisher != nil {
+		dispatcher := events.NewOutboxDispatcher(repository, publisher)
+		go dispatcher.Start(dispatcherCtx)
+		log.Info("Outbox dispatcher started")
+	}
+
+	// Start the idempotency key cleaner, which deletes expired
+	// idempotency_keys rows so retried ReserveStock/ReleaseStock/UpdateStock
+	// calls don't grow the table unbounded.
+	idempotencyCtx, stopIdempotencyCleaner := context.WithCancel(context.Background())
+	defer stopIdempotencyCleaner()
+	go repository.RunIdempotencyKeyCleaner(idempotencyCtx, time.Hour)
+
+	// Register dependency checks on the monitor started earlier: it probes
+	// the database and RabbitMQ every few seconds and caches the result
+	// for Check/Watch and /readyz, instead of each of them pinging inline.
+	monitor.RegisterCheck("primary", primaryDB.Ping)
+	for i, replica := range replicaDBs {
+		monitor.RegisterCheck(fmt.Sprintf("replica-%d", i), replica.Ping)
+	}
+	monitor.RegisterCheck("broker", func() error {
+		if publisher == nil || !publisher.IsHealthy() {
+			return fmt.Errorf("rabbitmq connection unhealthy")
+		}
+		return nil
+	})
+
+	// Probe once synchronously so /startupz has a real result to report
+	// before we flip it to started, then keep refreshing on a timer.
+	monitor.Probe()
+	monitor.MarkStarted()
 
-	// Start health check HTTP server
-	go startHealthServer(cfg.HTTPHealthPort, log)
+	monitorCtx, stopMonitor := context.WithCancel(context.Background())
+	defer stopMonitor()
+	go monitor.Run(monitorCtx, 5*time.Second)
+
+	// Initialize gRPC server
+	grpcServer := grpc.NewServer(repository, publisher, monitor)
 
 	// Start gRPC server
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", cfg.GRPCPort))
@@ -92,32 +231,113 @@ func main() {
 	log.Info("Shutting down server...")
 
 	// Graceful shutdown
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Error("Health server shutdown error", "error", err)
+	}
+
 	grpcServer.GracefulStop()
 
 	log.Info("Server stopped")
 }
 
-func startHealthServer(port string, log *logger.Logger) {
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+// requireAdminToken gates next behind the X-Admin-Token header matching
+// token. If token is unset (local dev with no ADMIN_TOKEN configured),
+// the check is skipped.
+func requireAdminToken(token string, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && r.Header.Get("X-Admin-Token") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("unauthorized"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// dlqListHandler serves the dead letters events.Consumer has persisted
+// after exhausting its RetryPolicy, so an operator can see what's
+// waiting in consumer_dead_letters before deciding whether to replay it.
+func dlqListHandler(repository *repo.InventoryRepo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		letters, err := repository.ListConsumerDeadLetters(r.Context())
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(letters)
+	}
+}
+
+// dlqReplayHandler re-publishes the dead letter identified by the {id}
+// path value and removes it from consumer_dead_letters on success.
+func dlqReplayHandler(consumer *events.Consumer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if consumer == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("event consumer unavailable"))
+			return
+		}
+
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid id"))
+			return
+		}
 
-	http.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := consumer.Replay(r.Context(), id); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(err.Error()))
+			return
+		}
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+		w.Write([]byte("replayed"))
+	}
+}
 
-	addr := fmt.Sprintf(":%s", port)
-	log.Info("Health check server listening", "addr", addr)
+// livezHandler reports process liveness only: it always returns 200, since
+// the process being able to answer HTTP at all is the only thing a
+// liveness probe should check. A Postgres or RabbitMQ hiccup must not
+// fail liveness, or Kubernetes restarts the pod instead of just routing
+// traffic away from it via readiness.
+func livezHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("alive"))
+	}
+}
 
-	server := &http.Server{
-		Addr:         addr,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+// readyHandler reports readiness from the monitor, so it flips to
+// unready as soon as the database or broker degrades rather than always
+// returning OK.
+func readyHandler(monitor *health.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if status, _ := monitor.Status(""); status == health.StatusNotServing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("not ready"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
 	}
+}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Error("Health server error", "error", err)
+// startupHandler reports 503 until the monitor's first dependency probe
+// has completed, so a k8s startup probe gates traffic/liveness checks
+// until migrations and the initial DB/broker probe have actually run,
+// instead of racing them.
+func startupHandler(monitor *health.Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !monitor.Started() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("starting"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("started"))
 	}
 }
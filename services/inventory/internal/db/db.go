@@ -0,0 +1,56 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Config drives Connect: which driver to dial, the primary DSN, and any
+// read-replica DSNs. Inventory has no ORM, so replicas are just
+// additional *sql.DB pools the repo layer round-robins reads across.
+type Config struct {
+	// Driver is "postgres" (default) or "sqlite". sqlite lets local dev
+	// and integration tests run without a Postgres instance.
+	Driver      string
+	PrimaryDSN  string
+	ReplicaDSNs []string
+}
+
+// Connect opens the primary database connection and, for each configured
+// replica DSN, a separate read-only pool. Callers should route writes
+// and migrations to primary and reads to replicas (see
+// repo.InventoryRepo and repo.WithPrimary).
+func Connect(cfg Config) (primary *sql.DB, replicas []*sql.DB, err error) {
+	driver := driverName(cfg.Driver)
+
+	primary, err = sql.Open(driver, cfg.PrimaryDSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open primary database: %w", err)
+	}
+	if err := primary.Ping(); err != nil {
+		return nil, nil, fmt.Errorf("failed to ping primary database: %w", err)
+	}
+
+	for i, dsn := range cfg.ReplicaDSNs {
+		replica, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open replica %d: %w", i, err)
+		}
+		if err := replica.Ping(); err != nil {
+			return nil, nil, fmt.Errorf("failed to ping replica %d: %w", i, err)
+		}
+		replicas = append(replicas, replica)
+	}
+
+	return primary, replicas, nil
+}
+
+func driverName(driver string) string {
+	if driver == "sqlite" {
+		return "sqlite3"
+	}
+	return "postgres"
+}
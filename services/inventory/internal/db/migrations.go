@@ -4,6 +4,9 @@ import (
 	"database/sql"
 )
 
+// RunMigrations applies the schema to db, which callers must always pass
+// as the primary connection; there is no separate replica mode because
+// migrations are never run against a replica pool in the first place.
 func RunMigrations(db *sql.DB) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS items (
@@ -18,6 +21,77 @@ func RunMigrations(db *sql.DB) error {
 
 	CREATE INDEX IF NOT EXISTS idx_items_category ON items(category);
 	CREATE INDEX IF NOT EXISTS idx_items_quantity ON items(quantity);
+
+	CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		aggregate_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(100) NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		published_at TIMESTAMP,
+		attempts INTEGER NOT NULL DEFAULT 0
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox(aggregate_id, id) WHERE published_at IS NULL;
+
+	CREATE TABLE IF NOT EXISTS outbox_dead_letters (
+		id BIGSERIAL PRIMARY KEY,
+		outbox_id BIGINT NOT NULL,
+		aggregate_id VARCHAR(255) NOT NULL,
+		event_type VARCHAR(100) NOT NULL,
+		payload JSONB NOT NULL,
+		attempts INTEGER NOT NULL,
+		dead_lettered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS processed_events (
+		event_id VARCHAR(255) PRIMARY KEY,
+		processed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS sagas (
+		order_id VARCHAR(255) PRIMARY KEY,
+		state VARCHAR(50) NOT NULL,
+		correlation_id VARCHAR(255),
+		reserved_items JSONB,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_sagas_state_expires ON sagas(state, expires_at);
+
+	CREATE TABLE IF NOT EXISTS saga_transitions (
+		id BIGSERIAL PRIMARY KEY,
+		order_id VARCHAR(255) NOT NULL,
+		from_state VARCHAR(50) NOT NULL,
+		to_state VARCHAR(50) NOT NULL,
+		correlation_id VARCHAR(255),
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_saga_transitions_order ON saga_transitions(order_id, id);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		key VARCHAR(255) PRIMARY KEY,
+		method VARCHAR(100) NOT NULL,
+		request_hash BYTEA NOT NULL,
+		response_bytes BYTEA,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
+
+	CREATE TABLE IF NOT EXISTS consumer_dead_letters (
+		id BIGSERIAL PRIMARY KEY,
+		message_id VARCHAR(255) NOT NULL,
+		routing_key VARCHAR(100) NOT NULL,
+		body JSONB NOT NULL,
+		attempts INTEGER NOT NULL,
+		last_error TEXT,
+		dead_lettered_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
 	`
 
 	if _, err := db.Exec(schema); err != nil {
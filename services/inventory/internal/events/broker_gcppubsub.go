@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// gcpPubSubBroker publishes CloudEvents to Google Cloud Pub/Sub, one topic
+// per routing key, lazily created and cached on first use.
+type gcpPubSubBroker struct {
+	client *pubsub.Client
+
+	mu     sync.Mutex
+	topics map[string]*pubsub.Topic
+}
+
+func newGCPPubSubBroker(rawURL string) (*gcpPubSubBroker, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse gcppubsub url: %w", err)
+	}
+
+	projectID := u.Host
+	if projectID == "" {
+		return nil, fmt.Errorf("gcppubsub url must specify a project id, e.g. gcppubsub://my-project")
+	}
+
+	client, err := pubsub.NewClient(context.Background(), projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pubsub client: %w", err)
+	}
+
+	return &gcpPubSubBroker{client: client, topics: make(map[string]*pubsub.Topic)}, nil
+}
+
+func (b *gcpPubSubBroker) topicFor(name string) *pubsub.Topic {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if t, ok := b.topics[name]; ok {
+		return t
+	}
+	t := b.client.Topic(name)
+	b.topics[name] = t
+	return t
+}
+
+func (b *gcpPubSubBroker) Publish(ctx context.Context, topic string, event CloudEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cloud event: %w", err)
+	}
+
+	result := b.topicFor(topic).Publish(ctx, &pubsub.Message{
+		Data: body,
+		Attributes: map[string]string{
+			"specversion": event.SpecVersion,
+			"type":        event.Type,
+		},
+	})
+
+	if _, err := result.Get(ctx); err != nil {
+		return fmt.Errorf("failed to publish to GCP Pub/Sub: %w", err)
+	}
+
+	return nil
+}
+
+func (b *gcpPubSubBroker) IsHealthy() bool {
+	return b.client != nil
+}
+
+func (b *gcpPubSubBroker) Close() error {
+	b.mu.Lock()
+	for _, t := range b.topics {
+		t.Stop()
+	}
+	b.mu.Unlock()
+
+	if b.client != nil {
+		return b.client.Close()
+	}
+	return nil
+}
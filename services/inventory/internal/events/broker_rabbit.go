@@ -0,0 +1,107 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// ExchangeName is the topic exchange every service publishes domain
+	// events to. Kept as a package constant so routing keys stay stable
+	// across the CloudEvents envelope change.
+	ExchangeName = "bookstore.events"
+	ExchangeType = "topic"
+)
+
+// rabbitBroker publishes CloudEvents over a RabbitMQ topic exchange.
+type rabbitBroker struct {
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func newRabbitBroker(url string) (*rabbitBroker, error) {
+	conn, err := amqp.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+
+	if err := ch.ExchangeDeclare(
+		ExchangeName,
+		ExchangeType,
+		true,  // durable
+		false, // auto-deleted
+		false, // internal
+		false, // no-wait
+		nil,   // arguments
+	); err != nil {
+		ch.Close()
+		conn.Close()
+		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+	}
+
+	log.Printf(" Broker connected to RabbitMQ exchange: %s", ExchangeName)
+
+	return &rabbitBroker{conn: conn, channel: ch}, nil
+}
+
+func (b *rabbitBroker) Publish(ctx context.Context, topic string, event CloudEvent) error {
+	if b.channel == nil {
+		return fmt.Errorf("rabbit broker channel is nil")
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal cloud event: %w", err)
+	}
+
+	headers := amqp.Table{
+		"specversion": event.SpecVersion,
+		"type":        event.Type,
+	}
+	otel.GetTextMapPropagator().Inject(ctx, amqpHeaderCarrier(headers))
+
+	err = b.channel.PublishWithContext(
+		ctx,
+		ExchangeName,
+		topic,
+		false, // mandatory
+		false, // immediate
+		amqp.Publishing{
+			ContentType:  "application/cloudevents+json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    event.ID,
+			Headers:      headers,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+func (b *rabbitBroker) IsHealthy() bool {
+	return b.conn != nil && !b.conn.IsClosed()
+}
+
+func (b *rabbitBroker) Close() error {
+	if b.channel != nil {
+		b.channel.Close()
+	}
+	if b.conn != nil {
+		return b.conn.Close()
+	}
+	return nil
+}
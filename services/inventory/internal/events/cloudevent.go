@@ -0,0 +1,125 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CloudEventsSpecVersion is the CloudEvents spec version implemented here.
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEvent is the CloudEvents 1.0 JSON envelope every event published by
+// this service is wrapped in, regardless of which Broker delivers it.
+//
+// See https://github.com/cloudevents/spec/blob/v1.0/spec.md#required-attributes
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Subject         string          `json:"subject,omitempty"`
+	CorrelationID   string          `json:"correlationid,omitempty"` // CloudEvents extension attribute
+	Data            json.RawMessage `json:"data"`
+}
+
+// correlationIDKey is the context key Publish looks up to populate
+// CloudEvent.CorrelationID, set by inbound gRPC/AMQP handlers as they relay
+// a request's correlation id downstream.
+type correlationIDKeyType struct{}
+
+var correlationIDKey = correlationIDKeyType{}
+
+// WithCorrelationID returns a context carrying correlationID for later
+// retrieval by NewCloudEvent.
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationIDKey, correlationID)
+}
+
+// NewCloudEvent wraps payload in a CloudEvents 1.0 envelope. source
+// identifies the emitting service (e.g. "bookstore/inventory") and subject
+// is typically the aggregate id the event is about (an order id, item id).
+// A correlation id stashed in ctx via WithCorrelationID, if any, is carried
+// along as a CloudEvents extension attribute.
+func NewCloudEvent(ctx context.Context, source, eventType, subject string, payload interface{}) (CloudEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to marshal event data: %w", err)
+	}
+
+	event := CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              uuid.New().String(),
+		Source:          source,
+		Type:            eventType,
+		Time:            time.Now().UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Subject:         subject,
+		Data:            data,
+	}
+
+	if corrID, ok := ctx.Value(correlationIDKey).(string); ok {
+		event.CorrelationID = corrID
+	}
+
+	return event, nil
+}
+
+// DecodeData unmarshals the event's data payload into out.
+func (e CloudEvent) DecodeData(out interface{}) error {
+	return json.Unmarshal(e.Data, out)
+}
+
+// legacyEnvelope is the pre-CloudEvents shape this service (and catalog)
+// used to publish: event_id/event_type/payload instead of
+// id/type/data. DecodeEnvelope falls back to it only for messages a
+// not-yet-upgraded publisher put on the wire during a rolling deploy.
+type legacyEnvelope struct {
+	EventID       string          `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Timestamp     string          `json:"timestamp"`
+	CorrelationID string          `json:"correlationid"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// DecodeEnvelope parses body as a CloudEvents 1.0 envelope. If specversion
+// is absent, it falls back to parsing the legacy event_id/event_type/payload
+// shape and translates it into an equivalent CloudEvent, so Consumer's
+// handlers only ever deal with one envelope type regardless of which shape
+// arrived on the wire.
+func DecodeEnvelope(body []byte) (CloudEvent, error) {
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to parse event envelope: %w", err)
+	}
+
+	if probe.SpecVersion != "" {
+		var event CloudEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			return CloudEvent{}, fmt.Errorf("failed to parse cloud event: %w", err)
+		}
+		return event, nil
+	}
+
+	var legacy legacyEnvelope
+	if err := json.Unmarshal(body, &legacy); err != nil {
+		return CloudEvent{}, fmt.Errorf("failed to parse legacy event envelope: %w", err)
+	}
+	return CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              legacy.EventID,
+		Source:          cloudEventSource,
+		Type:            cloudEventType(legacy.EventType),
+		Time:            legacy.Timestamp,
+		DataContentType: "application/json",
+		CorrelationID:   legacy.CorrelationID,
+		Data:            legacy.Payload,
+	}, nil
+}
@@ -1,13 +1,19 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"time"
 
 	"github.com/bookstore/inventory/internal/db"
 	"github.com/bookstore/inventory/internal/repo"
+	"github.com/bookstore/inventory/internal/saga"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
 )
 
 type Consumer struct {
@@ -16,9 +22,23 @@ type Consumer struct {
 	serviceName string
 	repo        *repo.InventoryRepo
 	publisher   *Publisher
+	saga        *saga.Coordinator
+	hub         *EventHub
+	policy      RetryPolicy
+	retryQueue  string
+	dlqQueue    string
 }
 
-func NewConsumer(url, serviceName string, repository *repo.InventoryRepo, publisher *Publisher) (*Consumer, error) {
+// SetHub wires hub into c, so every event handleMessage receives off
+// RabbitMQ is also fanned out to hub's in-process subscribers (the
+// WebSocket transport), in addition to whatever business logic the
+// matching handler performs. Safe to leave unset: handleMessage skips
+// fan-out entirely when hub is nil.
+func (c *Consumer) SetHub(hub *EventHub) {
+	c.hub = hub
+}
+
+func NewConsumer(url, serviceName string, repository *repo.InventoryRepo, publisher *Publisher, coordinator *saga.Coordinator, policy RetryPolicy) (*Consumer, error) {
 	conn, err := amqp.Dial(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
@@ -53,6 +73,10 @@ func NewConsumer(url, serviceName string, repository *repo.InventoryRepo, publis
 		serviceName: serviceName,
 		repo:        repository,
 		publisher:   publisher,
+		saga:        coordinator,
+		policy:      policy,
+		retryQueue:  retryQueueName(serviceName),
+		dlqQueue:    dlqQueueName(serviceName),
 	}, nil
 }
 
@@ -75,6 +99,9 @@ func (c *Consumer) Start() error {
 	// Bind queue to exchange with routing keys for order and catalog events
 	routingKeys := []string{
 		"order.created",
+		"order.confirmed",
+		"order.paid",
+		"order.completed",
 		"order.cancelled",
 		"catalog.created",
 		"catalog.deleted",
@@ -93,6 +120,10 @@ func (c *Consumer) Start() error {
 		log.Printf("Listening for events: %s", key)
 	}
 
+	if err := c.declareRetryTopology(); err != nil {
+		return err
+	}
+
 	// Start consuming
 	msgs, err := c.channel.Consume(
 		queue.Name,
@@ -115,199 +146,452 @@ func (c *Consumer) Start() error {
 	return nil
 }
 
+// declareRetryTopology declares the dead-letter exchange and the two
+// queues that back RetryPolicy: a single retry queue messages wait in
+// (the wait itself set per-message via scheduleRetry's Expiration field,
+// not a queue-wide TTL, so one queue covers every attempt/event type),
+// and a terminal DLQ queue for messages that exhausted the policy.
+//
+// The retry queue's x-dead-letter-exchange points back at the main
+// topic exchange, but deliberately without an x-dead-letter-routing-key
+// override: that argument is queue-wide, and this one retry queue holds
+// messages for every event type, so a fixed override would route all of
+// them the same way. scheduleRetry instead stamps each message's
+// original routing key onto a per-message "CC" header before publishing
+// it into the retry queue; RabbitMQ dead-letters a message under its own
+// routing key plus any CC/BCC it carries, so that header is what survives
+// the TTL expiry and gets it back onto the main queue's bindings.
+func (c *Consumer) declareRetryTopology() error {
+	if err := c.channel.ExchangeDeclare(
+		dlxExchangeName,
+		ExchangeType,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return fmt.Errorf("failed to declare dead-letter exchange: %w", err)
+	}
+
+	if _, err := c.channel.QueueDeclare(
+		c.retryQueue,
+		true,
+		false,
+		false,
+		false,
+		amqp.Table{"x-dead-letter-exchange": ExchangeName},
+	); err != nil {
+		return fmt.Errorf("failed to declare retry queue: %w", err)
+	}
+
+	if _, err := c.channel.QueueDeclare(
+		c.dlqQueue,
+		true,
+		false,
+		false,
+		false,
+		nil,
+	); err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue: %w", err)
+	}
+
+	if err := c.channel.QueueBind(c.dlqQueue, "#", dlxExchangeName, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue to %s: %w", dlxExchangeName, err)
+	}
+
+	log.Printf("Retry topology ready: retry=%s dlq=%s (via %s)", c.retryQueue, c.dlqQueue, dlxExchangeName)
+	return nil
+}
+
+// retryAttempt reads the retryCountHeader application header off msg,
+// defaulting to 0 for a message's first delivery.
+func retryAttempt(msg amqp.Delivery) int {
+	if msg.Headers == nil {
+		return 0
+	}
+	switch v := msg.Headers[retryCountHeader].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// scheduleRetry routes msg to the retry queue to wait out its next
+// backoff delay, or to the dead-letter queue if it has exhausted
+// c.policy, acking the original delivery either way since a copy has
+// been safely handed off. This replaces the old "msg.Nack(false, true)
+// on any failure" behavior, which looped a poison message back onto the
+// same queue with no delay and no upper bound.
+func (c *Consumer) scheduleRetry(msg amqp.Delivery, handlerErr error) {
+	attempt := retryAttempt(msg)
+
+	delay, ok := c.policy.delayFor(attempt)
+	if !ok {
+		c.deadLetter(msg, attempt+1, handlerErr)
+		return
+	}
+
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[retryCountHeader] = int32(attempt + 1)
+	// CC is the standard AMQP "carbon copy" header: publishing a message
+	// with it set routes the message using both the publish routing key
+	// and every key listed here, and it is preserved across dead-lettering
+	// (see declareRetryTopology), which is what lets this message land
+	// back on msg.RoutingKey's binding once its retry delay expires.
+	headers["CC"] = []interface{}{msg.RoutingKey}
+
+	err := c.channel.PublishWithContext(
+		context.Background(),
+		"",
+		c.retryQueue,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    msg.MessageId,
+			Headers:      headers,
+			Expiration:   strconv.FormatInt(delay.Milliseconds(), 10),
+			Type:         msg.RoutingKey, // carried only for operator visibility; redelivery routing uses the CC header, not this field
+		},
+	)
+	if err != nil {
+		log.Printf("  Failed to schedule retry %d/%d for %s: %v", attempt+1, c.policy.maxAttempts()-1, msg.RoutingKey, err)
+		msg.Nack(false, true)
+		return
+	}
+
+	log.Printf("  Scheduled retry %d/%d for %s in %s (cause: %v)", attempt+1, c.policy.maxAttempts()-1, msg.RoutingKey, delay, handlerErr)
+	msg.Ack(false)
+}
+
+// deadLetter persists msg to consumer_dead_letters for inspection and
+// replay via the /admin/dlq endpoints, mirrors it through dlxExchangeName
+// onto the DLQ queue for operators working directly against RabbitMQ,
+// and acks the original delivery so it stops being redelivered.
+func (c *Consumer) deadLetter(msg amqp.Delivery, attempts int, cause error) {
+	lastError := ""
+	if cause != nil {
+		lastError = cause.Error()
+	}
+
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = extractEnvelopeID(msg.Body)
+	}
+
+	if _, err := c.repo.InsertConsumerDeadLetter(context.Background(), messageID, msg.RoutingKey, json.RawMessage(msg.Body), int32(attempts), lastError); err != nil {
+		log.Printf("  Failed to persist dead letter for %s: %v", msg.RoutingKey, err)
+	}
+
+	if err := c.channel.PublishWithContext(
+		context.Background(),
+		dlxExchangeName,
+		msg.RoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  msg.ContentType,
+			Body:         msg.Body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    messageID,
+			Type:         msg.RoutingKey,
+		},
+	); err != nil {
+		log.Printf("  Failed to mirror dead letter onto %s: %v", c.dlqQueue, err)
+	}
+
+	metrics.consumerDeadLettered.Inc()
+	log.Printf("  Dead-lettered %s after %d attempts: %v", msg.RoutingKey, attempts, cause)
+	msg.Ack(false)
+}
+
+// Replay re-publishes a dead-lettered message onto the main exchange
+// under its original routing key, with a clean retry count, and removes
+// it from consumer_dead_letters. It's the other half of POST
+// /admin/dlq/{id}/replay: the handler looks up the id, calls Replay, and
+// reports the result.
+func (c *Consumer) Replay(ctx context.Context, id int64) error {
+	entry, err := c.repo.GetConsumerDeadLetter(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := c.channel.PublishWithContext(
+		ctx,
+		ExchangeName,
+		entry.RoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			ContentType:  "application/cloudevents+json",
+			Body:         entry.Body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    entry.MessageID,
+		},
+	); err != nil {
+		return fmt.Errorf("failed to replay dead letter %d: %w", id, err)
+	}
+
+	return c.repo.DeleteConsumerDeadLetter(ctx, id)
+}
+
+// poisonError marks a handler failure as one a retry cannot fix (e.g. a
+// payload that doesn't match the expected shape), so handleMessage
+// dead-letters it immediately instead of burning through the retry
+// policy first.
+type poisonError struct{ err error }
+
+func (e *poisonError) Error() string { return e.err.Error() }
+func (e *poisonError) Unwrap() error { return e.err }
+
 func (c *Consumer) handleMessage(msg amqp.Delivery) {
 	log.Printf("Received event: %s", msg.RoutingKey)
 
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), amqpHeaderCarrier(msg.Headers))
+	start := time.Now()
+	defer func() {
+		metrics.consumeDuration.WithLabelValues(msg.RoutingKey).Observe(time.Since(start).Seconds())
+	}()
+
+	envelope, err := DecodeEnvelope(msg.Body)
+	if err != nil {
+		log.Printf("  Failed to decode event envelope for %s: %v", msg.RoutingKey, err)
+		c.deadLetter(msg, retryAttempt(msg)+1, err)
+		return
+	}
+
+	if envelope.ID != "" {
+		metrics.inboxSeen.Inc()
+
+		processed, err := c.repo.EventAlreadyProcessed(ctx, envelope.ID)
+		if err != nil {
+			log.Printf("  Failed to check processed event %s: %v", envelope.ID, err)
+			c.scheduleRetry(msg, err)
+			return
+		}
+		if processed {
+			log.Printf("  Skipping duplicate event: %s (%s)", envelope.ID, msg.RoutingKey)
+			metrics.inboxDuplicates.Inc()
+			msg.Ack(false)
+			return
+		}
+	}
+
+	if c.hub != nil {
+		c.hub.Dispatch(msg.RoutingKey, envelope.Subject, envelope.Data)
+	}
+
 	switch msg.RoutingKey {
 	case "order.created":
-		c.handleOrderCreated(msg)
+		err = c.handleOrderCreated(envelope)
+	case "order.confirmed", "order.paid", "order.completed":
+		// All three mean the same thing to the saga: the order is no
+		// longer at risk of cancellation, so its reservation can be
+		// committed. Different upstream services (or different stages
+		// of the same order) may use whichever name fits their own
+		// vocabulary.
+		err = c.handleOrderConfirmed(envelope)
 	case "order.cancelled":
-		c.handleOrderCancelled(msg)
+		err = c.handleOrderCancelled(envelope)
 	case "catalog.created":
-		c.handleCatalogCreated(msg)
+		err = c.handleCatalogCreated(envelope)
 	case "catalog.deleted":
-		c.handleCatalogDeleted(msg)
+		err = c.handleCatalogDeleted(envelope)
 	default:
 		log.Printf("  Unknown event type: %s", msg.RoutingKey)
-		msg.Nack(false, false) // Don't requeue unknown events
+		err = &poisonError{err: fmt.Errorf("no handler registered for routing key %q", msg.RoutingKey)}
 	}
+
+	if err != nil {
+		var poison *poisonError
+		if errors.As(err, &poison) {
+			c.deadLetter(msg, retryAttempt(msg)+1, poison.err)
+		} else {
+			c.scheduleRetry(msg, err)
+		}
+		return
+	}
+
+	// The inbox row is only written once the handler above has actually
+	// succeeded, so a message that fails partway through is retried as a
+	// fresh attempt instead of being skipped as its own duplicate (see
+	// EventAlreadyProcessed above, which only consults this row).
+	if envelope.ID != "" {
+		if err := c.repo.MarkEventProcessed(ctx, envelope.ID); err != nil {
+			log.Printf("  Failed to record processed event %s: %v", envelope.ID, err)
+		}
+	}
+
+	msg.Ack(false)
 }
 
-type OrderCreatedEvent struct {
-	EventID      string `json:"event_id"`
-	EventType    string `json:"event_type"`
-	EventVersion string `json:"event_version"`
-	Timestamp    string `json:"timestamp"`
-	Payload      struct {
-		OrderID string `json:"order_id"`
-		UserID  string `json:"user_id"`
-		Items   []struct {
-			SKU      string  `json:"sku"`
-			Quantity int32   `json:"quantity"`
-			Price    float64 `json:"price"`
-		} `json:"items"`
-	} `json:"payload"`
+type orderCreatedPayload struct {
+	OrderID string `json:"order_id"`
+	UserID  string `json:"user_id"`
+	Items   []struct {
+		SKU      string  `json:"sku"`
+		Quantity int32   `json:"quantity"`
+		Price    float64 `json:"price"`
+	} `json:"items"`
 }
 
-type OrderCancelledEvent struct {
-	EventID      string `json:"event_id"`
-	EventType    string `json:"event_type"`
-	EventVersion string `json:"event_version"`
-	Timestamp    string `json:"timestamp"`
-	Payload      struct {
-		OrderID string `json:"order_id"`
-		Reason  string `json:"reason"`
-		Items   []struct {
-			SKU      string  `json:"sku"`
-			Quantity int32   `json:"quantity"`
-			Price    float64 `json:"price"`
-		} `json:"items"`
-	} `json:"payload"`
+type orderConfirmedPayload struct {
+	OrderID string `json:"order_id"`
 }
 
-func (c *Consumer) handleOrderCreated(msg amqp.Delivery) {
-	var event OrderCreatedEvent
-	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		log.Printf(" Failed to unmarshal order.created event: %v", err)
-		msg.Nack(false, false)
-		return
+type orderCancelledPayload struct {
+	OrderID string `json:"order_id"`
+	Reason  string `json:"reason"`
+	Items   []struct {
+		SKU      string  `json:"sku"`
+		Quantity int32   `json:"quantity"`
+		Price    float64 `json:"price"`
+	} `json:"items"`
+}
+
+// handleOrderCreated starts the reservation saga for the order: it
+// reserves stock and tracks the reservation as Reserved pending either
+// order.confirmed or order.cancelled (or a timeout, whichever comes
+// first).
+func (c *Consumer) handleOrderCreated(envelope CloudEvent) error {
+	var payload orderCreatedPayload
+	if err := envelope.DecodeData(&payload); err != nil {
+		return &poisonError{err: fmt.Errorf("failed to decode order.created payload: %w", err)}
 	}
 
-	// Reserve stock for the order
-	reserved := make([]db.ReservedItem, 0, len(event.Payload.Items))
-	for _, item := range event.Payload.Items {
+	reserved := make([]db.ReservedItem, 0, len(payload.Items))
+	for _, item := range payload.Items {
 		reserved = append(reserved, db.ReservedItem{
 			ItemID:   item.SKU,
 			Quantity: item.Quantity,
 		})
 	}
 
-	if err := c.repo.ReserveStock(event.Payload.OrderID, reserved); err != nil {
-		log.Printf(" Failed to reserve stock for order %s: %v", event.Payload.OrderID, err)
-		msg.Nack(false, true) // Requeue for retry
-		return
+	correlationID := envelope.CorrelationID
+	if correlationID == "" {
+		correlationID = envelope.ID
 	}
 
-	log.Printf(" Stock reserved for order %s", event.Payload.OrderID)
-	msg.Ack(false)
+	if err := c.saga.HandleOrderCreated(context.Background(), payload.OrderID, correlationID, reserved); err != nil {
+		return fmt.Errorf("failed to reserve stock for order %s: %w", payload.OrderID, err)
+	}
+
+	log.Printf(" Stock reserved for order %s", payload.OrderID)
+	return nil
 }
 
-func (c *Consumer) handleOrderCancelled(msg amqp.Delivery) {
-	var event OrderCancelledEvent
-	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		log.Printf(" Failed to unmarshal order.cancelled event: %v", err)
-		msg.Nack(false, false)
-		return
+// handleOrderConfirmed commits the order's saga, ending its lifecycle
+// without releasing the stock already reserved for it.
+func (c *Consumer) handleOrderConfirmed(envelope CloudEvent) error {
+	var payload orderConfirmedPayload
+	if err := envelope.DecodeData(&payload); err != nil {
+		return &poisonError{err: fmt.Errorf("failed to decode order.confirmed payload: %w", err)}
 	}
 
-	// Release stock for the cancelled order
-	reserved := make([]db.ReservedItem, 0, len(event.Payload.Items))
-	for _, item := range event.Payload.Items {
+	if err := c.saga.HandleOrderConfirmed(context.Background(), payload.OrderID); err != nil {
+		return fmt.Errorf("failed to commit saga for order %s: %w", payload.OrderID, err)
+	}
+
+	log.Printf(" Saga committed for order %s", payload.OrderID)
+	return nil
+}
+
+// handleOrderCancelled compensates the order's saga by releasing its
+// reserved stock.
+func (c *Consumer) handleOrderCancelled(envelope CloudEvent) error {
+	var payload orderCancelledPayload
+	if err := envelope.DecodeData(&payload); err != nil {
+		return &poisonError{err: fmt.Errorf("failed to decode order.cancelled payload: %w", err)}
+	}
+
+	reserved := make([]db.ReservedItem, 0, len(payload.Items))
+	for _, item := range payload.Items {
 		reserved = append(reserved, db.ReservedItem{
 			ItemID:   item.SKU,
 			Quantity: item.Quantity,
 		})
 	}
 
-	if err := c.repo.ReleaseStock(event.Payload.OrderID, reserved); err != nil {
-		log.Printf(" Failed to release stock for order %s: %v", event.Payload.OrderID, err)
-		msg.Nack(false, true) // Requeue for retry
-		return
+	if err := c.saga.HandleOrderCancelled(context.Background(), payload.OrderID, reserved); err != nil {
+		return fmt.Errorf("failed to release stock for order %s: %w", payload.OrderID, err)
 	}
 
-	log.Printf(" Stock released for cancelled order %s", event.Payload.OrderID)
-	msg.Ack(false)
+	log.Printf(" Stock released for cancelled order %s", payload.OrderID)
+	return nil
 }
 
-type CatalogCreatedEvent struct {
-	EventID      string `json:"event_id"`
-	EventType    string `json:"event_type"`
-	EventVersion string `json:"event_version"`
-	Timestamp    string `json:"timestamp"`
-	Payload      struct {
-		SKU      string `json:"sku"`
-		Title    string `json:"title"`
-		Author   string `json:"author"`
-		Price    int64  `json:"price"`
-		Currency string `json:"currency"`
-		Category string `json:"category"`
-		Active   bool   `json:"active"`
-	} `json:"payload"`
+type catalogCreatedPayload struct {
+	SKU      string `json:"sku"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+	Price    int64  `json:"price"`
+	Currency string `json:"currency"`
+	Category string `json:"category"`
+	Active   bool   `json:"active"`
 }
 
-type CatalogDeletedEvent struct {
-	EventID      string `json:"event_id"`
-	EventType    string `json:"event_type"`
-	EventVersion string `json:"event_version"`
-	Timestamp    string `json:"timestamp"`
-	Payload      struct {
-		SKU string `json:"sku"`
-	} `json:"payload"`
+type catalogDeletedPayload struct {
+	SKU string `json:"sku"`
 }
 
-func (c *Consumer) handleCatalogCreated(msg amqp.Delivery) {
-	var event CatalogCreatedEvent
-	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		log.Printf("Failed to unmarshal catalog.created event: %v", err)
-		msg.Nack(false, false)
-		return
+func (c *Consumer) handleCatalogCreated(envelope CloudEvent) error {
+	var payload catalogCreatedPayload
+	if err := envelope.DecodeData(&payload); err != nil {
+		return &poisonError{err: fmt.Errorf("failed to decode catalog.created payload: %w", err)}
 	}
 
-	log.Printf("Creating inventory item for book: %s (%s)", event.Payload.Title, event.Payload.SKU)
+	log.Printf("Creating inventory item for book: %s (%s)", payload.Title, payload.SKU)
 
 	// Convert price from cents to dollars
-	priceInDollars := float64(event.Payload.Price) / 100.0
+	priceInDollars := float64(payload.Price) / 100.0
 
 	// Create item in inventory with 0 stock initially
 	item := &db.Item{
-		ItemID:   event.Payload.SKU,
-		Name:     event.Payload.Title,
-		Category: event.Payload.Category,
+		ItemID:   payload.SKU,
+		Name:     payload.Title,
+		Category: payload.Category,
 		Quantity: 0, // Start with 0 stock
 		Price:    priceInDollars,
 	}
 
+	// CreateItem enqueues the inventory.created outbox event in the same
+	// transaction as the insert, so OutboxDispatcher delivers it even if
+	// the process crashes right after this commits.
 	if err := c.repo.CreateItem(item); err != nil {
-		log.Printf("Failed to create inventory item for %s: %v", event.Payload.SKU, err)
-		msg.Nack(false, true) // Requeue for retry
-		return
+		return fmt.Errorf("failed to create inventory item for %s: %w", payload.SKU, err)
 	}
 
-	log.Printf("Inventory item created: %s", event.Payload.SKU)
-
-	// Publish inventory.created event
-	if err := c.publisher.PublishItemCreated(event.Payload.SKU, event.Payload.Title, event.Payload.Category, 0); err != nil {
-		log.Printf("Failed to publish inventory.created event: %v", err)
-		// Don't fail the operation if event publishing fails
-	}
-
-	msg.Ack(false)
+	log.Printf("Inventory item created: %s", payload.SKU)
+	return nil
 }
 
-func (c *Consumer) handleCatalogDeleted(msg amqp.Delivery) {
-	var event CatalogDeletedEvent
-	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		log.Printf("Failed to unmarshal catalog.deleted event: %v", err)
-		msg.Nack(false, false)
-		return
+func (c *Consumer) handleCatalogDeleted(envelope CloudEvent) error {
+	var payload catalogDeletedPayload
+	if err := envelope.DecodeData(&payload); err != nil {
+		return &poisonError{err: fmt.Errorf("failed to decode catalog.deleted payload: %w", err)}
 	}
 
-	log.Printf("Deleting inventory item: %s", event.Payload.SKU)
+	log.Printf("Deleting inventory item: %s", payload.SKU)
 
-	if err := c.repo.DeleteItem(event.Payload.SKU); err != nil {
-		log.Printf("Failed to delete inventory item %s: %v", event.Payload.SKU, err)
-		msg.Nack(false, true) // Requeue for retry
-		return
+	// DeleteItem enqueues the inventory.deleted outbox event in the same
+	// transaction as the delete; see handleCatalogCreated.
+	if err := c.repo.DeleteItem(payload.SKU); err != nil {
+		return fmt.Errorf("failed to delete inventory item %s: %w", payload.SKU, err)
 	}
 
-	log.Printf("Inventory item deleted: %s", event.Payload.SKU)
-
-	// Publish inventory.deleted event
-	if err := c.publisher.PublishItemDeleted(event.Payload.SKU); err != nil {
-		log.Printf("Failed to publish inventory.deleted event: %v", err)
-	}
-
-	msg.Ack(false)
+	log.Printf("Inventory item deleted: %s", payload.SKU)
+	return nil
 }
 
 func (c *Consumer) Close() {
@@ -318,3 +602,22 @@ func (c *Consumer) Close() {
 		c.conn.Close()
 	}
 }
+
+// extractEnvelopeID is a last-resort fallback for naming a dead letter
+// when msg.MessageId wasn't set by the publisher: it pulls the id
+// straight out of the body without committing to the full CloudEvent or
+// legacy envelope shape, so a malformed body (the reason it's being
+// dead-lettered in the first place) doesn't also break this lookup.
+func extractEnvelopeID(body []byte) string {
+	var probe struct {
+		ID      string `json:"id"`
+		EventID string `json:"event_id"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		return ""
+	}
+	if probe.ID != "" {
+		return probe.ID
+	}
+	return probe.EventID
+}
@@ -2,40 +2,40 @@ package events
 
 import "github.com/bookstore/inventory/internal/db"
 
-type StockReservedEvent struct {
-	EventID      string               `json:"event_id"`
-	EventType    string               `json:"event_type"`
-	EventVersion string               `json:"event_version"`
-	Timestamp    string               `json:"timestamp"`
-	Payload      StockReservedPayload `json:"payload"`
+// Event type names, used as both the AMQP/NATS/Pub/Sub routing key and,
+// once wrapped in a CloudEvent, the suffix of the "type" attribute (see
+// cloudEventType).
+const (
+	EventTypeItemCreated        = "inventory.created"
+	EventTypeItemDeleted        = "inventory.deleted"
+	EventTypeStockReserved      = "inventory.stock_reserved"
+	EventTypeStockReleased      = "inventory.stock_released"
+	EventTypeStockUpdated       = "inventory.stock_updated"
+	EventTypeReservationExpired = "inventory.reservation_expired"
+)
+
+// cloudEventSource identifies this service as the CloudEvents "source".
+const cloudEventSource = "bookstore/inventory"
+
+// cloudEventType builds the CloudEvents "type" attribute from a routing
+// key, e.g. "inventory.stock_reserved" -> "com.bookstore.inventory.stock_reserved.v1".
+func cloudEventType(routingKey string) string {
+	return "com.bookstore." + routingKey + ".v1"
 }
 
+// StockReservedPayload is the CloudEvent "data" body for EventTypeStockReserved.
 type StockReservedPayload struct {
 	OrderID string            `json:"order_id"`
 	Items   []db.ReservedItem `json:"items"`
 }
 
-type StockReleasedEvent struct {
-	EventID      string               `json:"event_id"`
-	EventType    string               `json:"event_type"`
-	EventVersion string               `json:"event_version"`
-	Timestamp    string               `json:"timestamp"`
-	Payload      StockReleasedPayload `json:"payload"`
-}
-
+// StockReleasedPayload is the CloudEvent "data" body for EventTypeStockReleased.
 type StockReleasedPayload struct {
 	OrderID string            `json:"order_id"`
 	Items   []db.ReservedItem `json:"items"`
 }
 
-type StockUpdatedEvent struct {
-	EventID      string              `json:"event_id"`
-	EventType    string              `json:"event_type"`
-	EventVersion string              `json:"event_version"`
-	Timestamp    string              `json:"timestamp"`
-	Payload      StockUpdatedPayload `json:"payload"`
-}
-
+// StockUpdatedPayload is the CloudEvent "data" body for EventTypeStockUpdated.
 type StockUpdatedPayload struct {
 	ItemID           string `json:"item_id"`
 	PreviousQuantity int32  `json:"previous_quantity"`
@@ -43,3 +43,10 @@ type StockUpdatedPayload struct {
 	Delta            int32  `json:"delta"`
 	Reason           string `json:"reason,omitempty"`
 }
+
+// ReservationExpiredPayload is the CloudEvent "data" body for
+// EventTypeReservationExpired, published when the saga timeout reaper
+// releases a reservation that was never confirmed.
+type ReservationExpiredPayload struct {
+	OrderID string `json:"order_id"`
+}
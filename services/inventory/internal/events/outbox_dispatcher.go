@@ -0,0 +1,127 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/bookstore/inventory/internal/repo"
+)
+
+const (
+	outboxPollInterval = 2 * time.Second
+	outboxBatchSize    = 50
+	outboxMaxAttempts  = 5
+)
+
+// outboxRepo is the subset of *repo.InventoryRepo's API that
+// OutboxDispatcher depends on, so tests can drain against an in-memory
+// fake instead of a real database. The Tx-suffixed methods run against
+// the tx Transaction opened, so the claim's FOR UPDATE SKIP LOCKED lock
+// is held for the whole batch instead of being released the instant the
+// claim query returns.
+type outboxRepo interface {
+	OldestUnpublishedOutboxAge(ctx context.Context) (time.Duration, error)
+	Transaction(ctx context.Context, fn func(tx *sql.Tx) error) error
+	ClaimOutboxBatchTx(ctx context.Context, tx *sql.Tx, limit int, maxAttempts int32) ([]repo.OutboxRow, error)
+	MarkOutboxPublishedTx(ctx context.Context, tx *sql.Tx, id int64) error
+	MarkOutboxFailedTx(ctx context.Context, tx *sql.Tx, id int64) error
+	DeadLetterOutboxTx(ctx context.Context, tx *sql.Tx, id int64) error
+}
+
+// outboxPublisher is the subset of *Publisher's API that OutboxDispatcher
+// depends on, so tests can inject a stub instead of a real broker
+// connection.
+type outboxPublisher interface {
+	PublishRaw(ctx context.Context, routingKey, subject string, data json.RawMessage) error
+}
+
+// OutboxDispatcher drains the outbox table into the configured Publisher,
+// so inventory mutations get at-least-once, per-aggregate-ordered event
+// delivery even across process restarts or broker outages, instead of
+// publishing inline with the RPC that raised the event.
+type OutboxDispatcher struct {
+	repo      outboxRepo
+	publisher outboxPublisher
+}
+
+// NewOutboxDispatcher returns a dispatcher that drains repository's
+// outbox table through publisher.
+func NewOutboxDispatcher(repository *repo.InventoryRepo, publisher *Publisher) *OutboxDispatcher {
+	return &OutboxDispatcher{repo: repository, publisher: publisher}
+}
+
+// Start polls the outbox every outboxPollInterval until ctx is cancelled.
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.drain(ctx); err != nil {
+				log.Printf("  Outbox drain failed: %v", err)
+			}
+		}
+	}
+}
+
+// drain claims one batch of outbox rows and attempts to publish each in
+// order. A row that keeps failing past outboxMaxAttempts is moved to the
+// dead-letter table instead of being retried forever.
+//
+// The claim and every row's mark run inside one transaction so the FOR
+// UPDATE SKIP LOCKED lock taken by ClaimOutboxBatchTx is held for the
+// whole batch instead of being released the instant the claim query
+// returns: without that, the lock protected nothing, since a second
+// dispatcher replica could claim the same rows before this one got
+// around to publishing and marking them.
+func (d *OutboxDispatcher) drain(ctx context.Context) error {
+	if lag, err := d.repo.OldestUnpublishedOutboxAge(ctx); err == nil {
+		metrics.lagSeconds.Set(lag.Seconds())
+	}
+
+	return d.repo.Transaction(ctx, func(tx *sql.Tx) error {
+		batch, err := d.repo.ClaimOutboxBatchTx(ctx, tx, outboxBatchSize, outboxMaxAttempts)
+		if err != nil {
+			return err
+		}
+
+		// A row that fails holds back the rest of its aggregate's rows in
+		// this batch, so none of them is published ahead of it.
+		failedAggregates := make(map[string]bool)
+		for _, row := range batch {
+			if failedAggregates[row.AggregateID] {
+				continue
+			}
+			if err := d.publisher.PublishRaw(ctx, row.EventType, row.AggregateID, row.Payload); err != nil {
+				log.Printf("  Failed to publish outbox row %d (%s): %v", row.ID, row.EventType, err)
+				failedAggregates[row.AggregateID] = true
+
+				if row.Attempts+1 >= outboxMaxAttempts {
+					if err := d.repo.DeadLetterOutboxTx(ctx, tx, row.ID); err != nil {
+						log.Printf("  Failed to dead-letter outbox row %d: %v", row.ID, err)
+						continue
+					}
+					metrics.deadLettered.Inc()
+					continue
+				}
+
+				if err := d.repo.MarkOutboxFailedTx(ctx, tx, row.ID); err != nil {
+					log.Printf("  Failed to mark outbox row %d failed: %v", row.ID, err)
+				}
+				continue
+			}
+
+			if err := d.repo.MarkOutboxPublishedTx(ctx, tx, row.ID); err != nil {
+				log.Printf("  Failed to mark outbox row %d published: %v", row.ID, err)
+			}
+		}
+
+		return nil
+	})
+}
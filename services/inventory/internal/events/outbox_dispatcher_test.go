@@ -0,0 +1,148 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bookstore/inventory/internal/repo"
+)
+
+// fakeOutboxRepo is an in-memory outboxRepo stand-in so drain can be
+// exercised without a real database. Transaction just runs fn directly
+// (with a nil *sql.Tx, since none of the Tx-suffixed methods below
+// actually use it) so drain's claim-then-mark sequence still runs as one
+// logical unit without a real database connection behind it.
+type fakeOutboxRepo struct {
+	rows         []repo.OutboxRow
+	published    []int64
+	failed       []int64
+	deadLettered []int64
+}
+
+func (f *fakeOutboxRepo) OldestUnpublishedOutboxAge(ctx context.Context) (time.Duration, error) {
+	return 0, nil
+}
+
+func (f *fakeOutboxRepo) Transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return fn(nil)
+}
+
+func (f *fakeOutboxRepo) ClaimOutboxBatchTx(ctx context.Context, tx *sql.Tx, limit int, maxAttempts int32) ([]repo.OutboxRow, error) {
+	var batch []repo.OutboxRow
+	for _, row := range f.rows {
+		if row.Attempts < maxAttempts {
+			batch = append(batch, row)
+		}
+	}
+	return batch, nil
+}
+
+func (f *fakeOutboxRepo) MarkOutboxPublishedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	f.published = append(f.published, id)
+	f.rows = removeOutboxRow(f.rows, id)
+	return nil
+}
+
+func (f *fakeOutboxRepo) MarkOutboxFailedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	f.failed = append(f.failed, id)
+	for i := range f.rows {
+		if f.rows[i].ID == id {
+			f.rows[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (f *fakeOutboxRepo) DeadLetterOutboxTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	f.deadLettered = append(f.deadLettered, id)
+	f.rows = removeOutboxRow(f.rows, id)
+	return nil
+}
+
+func removeOutboxRow(rows []repo.OutboxRow, id int64) []repo.OutboxRow {
+	out := rows[:0]
+	for _, row := range rows {
+		if row.ID != id {
+			out = append(out, row)
+		}
+	}
+	return out
+}
+
+// fakeOutboxPublisher lets each call fail or succeed per routing key.
+type fakeOutboxPublisher struct {
+	failRoutingKeys map[string]bool
+	calls           []string
+}
+
+func (f *fakeOutboxPublisher) PublishRaw(ctx context.Context, routingKey, subject string, data json.RawMessage) error {
+	f.calls = append(f.calls, subject)
+	if f.failRoutingKeys[routingKey] {
+		return errors.New("publish failed")
+	}
+	return nil
+}
+
+func TestOutboxDispatcherDrainPublishesAndMarksRows(t *testing.T) {
+	r := &fakeOutboxRepo{rows: []repo.OutboxRow{
+		{ID: 1, AggregateID: "SKU-1", EventType: "inventory.created", Payload: json.RawMessage(`{}`)},
+	}}
+	p := &fakeOutboxPublisher{}
+	d := &OutboxDispatcher{repo: r, publisher: p}
+
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatalf("drain returned error: %v", err)
+	}
+
+	if len(r.published) != 1 || r.published[0] != 1 {
+		t.Fatalf("expected row 1 marked published, got %v", r.published)
+	}
+	if len(r.failed) != 0 {
+		t.Fatalf("expected no failed rows, got %v", r.failed)
+	}
+}
+
+func TestOutboxDispatcherDrainRetriesOnFailure(t *testing.T) {
+	r := &fakeOutboxRepo{rows: []repo.OutboxRow{
+		{ID: 1, AggregateID: "SKU-1", EventType: "inventory.created", Payload: json.RawMessage(`{}`), Attempts: 1},
+	}}
+	p := &fakeOutboxPublisher{failRoutingKeys: map[string]bool{"inventory.created": true}}
+	d := &OutboxDispatcher{repo: r, publisher: p}
+
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatalf("drain returned error: %v", err)
+	}
+
+	if len(r.failed) != 1 || r.failed[0] != 1 {
+		t.Fatalf("expected row 1 marked failed, got %v", r.failed)
+	}
+	if len(r.deadLettered) != 0 {
+		t.Fatalf("expected no dead-lettered rows yet, got %v", r.deadLettered)
+	}
+	if len(r.published) != 0 {
+		t.Fatalf("expected no published rows, got %v", r.published)
+	}
+}
+
+func TestOutboxDispatcherDrainDeadLettersAfterMaxAttempts(t *testing.T) {
+	r := &fakeOutboxRepo{rows: []repo.OutboxRow{
+		{ID: 1, AggregateID: "SKU-1", EventType: "inventory.created", Payload: json.RawMessage(`{}`), Attempts: outboxMaxAttempts - 1},
+	}}
+	p := &fakeOutboxPublisher{failRoutingKeys: map[string]bool{"inventory.created": true}}
+	d := &OutboxDispatcher{repo: r, publisher: p}
+
+	if err := d.drain(context.Background()); err != nil {
+		t.Fatalf("drain returned error: %v", err)
+	}
+
+	if len(r.deadLettered) != 1 || r.deadLettered[0] != 1 {
+		t.Fatalf("expected row 1 dead-lettered, got %v", r.deadLettered)
+	}
+	if len(r.failed) != 0 {
+		t.Fatalf("expected no failed rows once dead-lettered, got %v", r.failed)
+	}
+}
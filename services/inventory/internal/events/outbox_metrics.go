@@ -0,0 +1,51 @@
+package events
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// outboxMetrics exposes the outbox dispatcher's and inbox's health as
+// Prometheus gauges/counters, shared between OutboxDispatcher and
+// Consumer so duplicate detection and delivery lag live on one registry.
+type outboxMetrics struct {
+	lagSeconds           prometheus.Gauge
+	deadLettered         prometheus.Counter
+	inboxSeen            prometheus.Counter
+	inboxDuplicates      prometheus.Counter
+	consumeDuration      *prometheus.HistogramVec
+	consumerDeadLettered prometheus.Counter
+}
+
+func newOutboxMetrics() *outboxMetrics {
+	m := &outboxMetrics{
+		lagSeconds: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "inventory_outbox_lag_seconds",
+			Help: "Age of the oldest unpublished outbox row, in seconds.",
+		}),
+		deadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "inventory_outbox_dead_lettered_total",
+			Help: "Outbox rows moved to outbox_dead_letters after exhausting their retry budget.",
+		}),
+		inboxSeen: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "inventory_inbox_events_total",
+			Help: "Inbound events the consumer has checked against the inbox.",
+		}),
+		inboxDuplicates: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "inventory_inbox_duplicates_total",
+			Help: "Inbound events skipped because the inbox had already processed their event_id.",
+		}),
+		consumeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "inventory_consumer_processing_duration_seconds",
+			Help:    "Time spent handling a consumed event, labeled by routing key.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"routing_key"}),
+		consumerDeadLettered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "inventory_consumer_dead_lettered_total",
+			Help: "Consumed events moved to consumer_dead_letters after exhausting Consumer's RetryPolicy.",
+		}),
+	}
+	prometheus.MustRegister(m.lagSeconds, m.deadLettered, m.inboxSeen, m.inboxDuplicates, m.consumeDuration, m.consumerDeadLettered)
+	return m
+}
+
+// metrics is the package-wide outbox/inbox registry, shared by
+// OutboxDispatcher and Consumer.
+var metrics = newOutboxMetrics()
@@ -1,125 +1,135 @@
 package events
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 
-	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/bookstore/inventory/internal/db"
 )
 
-const (
-	ExchangeName = "bookstore.events"
-	ExchangeType = "topic"
-)
+// EventPublisher is the subset of Publisher's API that grpc.Server
+// depends on, so tests can inject a stub instead of a real broker
+// connection.
+type EventPublisher interface {
+	PublishItemCreated(ctx context.Context, itemID, name, category string, quantity int32) error
+	PublishItemDeleted(ctx context.Context, itemID string) error
+	PublishStockReserved(ctx context.Context, orderID string, items []db.ReservedItem) error
+	PublishStockReleased(ctx context.Context, orderID string, items []db.ReservedItem) error
+	PublishStockUpdated(ctx context.Context, itemID string, previousQuantity, newQuantity, delta int32, reason string) error
+	IsHealthy() bool
+	Close() error
+}
 
+// Publisher publishes domain events as CloudEvents through a pluggable
+// Broker (RabbitMQ, NATS JetStream, or Google Pub/Sub).
 type Publisher struct {
-	conn        *amqp.Connection
-	channel     *amqp.Channel
+	broker      Broker
 	serviceName string
+	hub         *EventHub
 }
 
-func NewPublisher(url, serviceName string) (*Publisher, error) {
-	conn, err := amqp.Dial(url)
+// NewPublisher opens a Broker for brokerURL (see OpenBroker for accepted
+// schemes) and returns a Publisher bound to it.
+func NewPublisher(brokerURL, serviceName string) (*Publisher, error) {
+	broker, err := OpenBroker(brokerURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
-	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to open channel: %w", err)
-	}
-
-	// Declare exchange
-	if err := ch.ExchangeDeclare(
-		ExchangeName,
-		ExchangeType,
-		true,  // durable
-		false, // auto-deleted
-		false, // internal
-		false, // no-wait
-		nil,   // arguments
-	); err != nil {
-		ch.Close()
-		conn.Close()
-		return nil, fmt.Errorf("failed to declare exchange: %w", err)
+		return nil, err
 	}
 
-	log.Printf(" Publisher connected to RabbitMQ exchange: %s", ExchangeName)
+	return &Publisher{broker: broker, serviceName: serviceName}, nil
+}
 
-	return &Publisher{
-		conn:        conn,
-		channel:     ch,
-		serviceName: serviceName,
-	}, nil
+// SetHub wires hub into p, so every event published from here on is also
+// fanned out to hub's in-process subscribers (the WebSocket transport),
+// in addition to being sent to the broker. Safe to leave unset: publish
+// skips fan-out entirely when hub is nil.
+func (p *Publisher) SetHub(hub *EventHub) {
+	p.hub = hub
 }
 
-func (p *Publisher) PublishEvent(routingKey string, body []byte) error {
-	if p.channel == nil {
-		return fmt.Errorf("publisher channel is nil")
+// publish wraps payload in a CloudEvent and publishes it on routingKey,
+// preserving the pre-CloudEvents routing keys so existing queue bindings
+// keep working. It also fans the same payload out through p.hub, if one
+// is set, so WebSocket subscribers see it without waiting on the broker.
+func (p *Publisher) publish(ctx context.Context, routingKey, subject string, payload interface{}) error {
+	event, err := NewCloudEvent(ctx, cloudEventSource, cloudEventType(routingKey), subject, payload)
+	if err != nil {
+		return err
 	}
 
-	err := p.channel.Publish(
-		ExchangeName,
-		routingKey,
-		false, // mandatory
-		false, // immediate
-		amqp.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp.Persistent,
-			AppId:        p.serviceName,
-		},
-	)
+	if p.hub != nil {
+		p.hub.Dispatch(routingKey, subject, event.Data)
+	}
 
-	if err != nil {
-		return fmt.Errorf("failed to publish event: %w", err)
+	if err := p.broker.Publish(ctx, routingKey, event); err != nil {
+		return fmt.Errorf("failed to publish %s: %w", routingKey, err)
 	}
 
 	return nil
 }
 
-func (p *Publisher) PublishItemCreated(itemID, name, category string, quantity int32) error {
-	event := map[string]interface{}{
-		"event_type": "inventory.created",
-		"payload": map[string]interface{}{
-			"item_id":  itemID,
-			"name":     name,
-			"category": category,
-			"quantity": quantity,
-		},
-	}
+// PublishItemCreated publishes EventTypeItemCreated for a newly created item.
+func (p *Publisher) PublishItemCreated(ctx context.Context, itemID, name, category string, quantity int32) error {
+	return p.publish(ctx, EventTypeItemCreated, itemID, map[string]interface{}{
+		"item_id":  itemID,
+		"name":     name,
+		"category": category,
+		"quantity": quantity,
+	})
+}
 
-	body, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
+// PublishItemDeleted publishes EventTypeItemDeleted for a removed item.
+func (p *Publisher) PublishItemDeleted(ctx context.Context, itemID string) error {
+	return p.publish(ctx, EventTypeItemDeleted, itemID, map[string]interface{}{
+		"item_id": itemID,
+	})
+}
 
-	return p.PublishEvent("inventory.created", body)
+// PublishStockReserved publishes EventTypeStockReserved for a reservation made on behalf of orderID.
+func (p *Publisher) PublishStockReserved(ctx context.Context, orderID string, items []db.ReservedItem) error {
+	return p.publish(ctx, EventTypeStockReserved, orderID, StockReservedPayload{
+		OrderID: orderID,
+		Items:   items,
+	})
 }
 
-func (p *Publisher) PublishItemDeleted(itemID string) error {
-	event := map[string]interface{}{
-		"event_type": "inventory.deleted",
-		"payload": map[string]interface{}{
-			"item_id": itemID,
-		},
-	}
+// PublishStockReleased publishes EventTypeStockReleased for a released reservation.
+func (p *Publisher) PublishStockReleased(ctx context.Context, orderID string, items []db.ReservedItem) error {
+	return p.publish(ctx, EventTypeStockReleased, orderID, StockReleasedPayload{
+		OrderID: orderID,
+		Items:   items,
+	})
+}
 
-	body, err := json.Marshal(event)
-	if err != nil {
-		return fmt.Errorf("failed to marshal event: %w", err)
-	}
+// PublishStockUpdated publishes EventTypeStockUpdated for a manual stock adjustment.
+func (p *Publisher) PublishStockUpdated(ctx context.Context, itemID string, previousQuantity, newQuantity, delta int32, reason string) error {
+	return p.publish(ctx, EventTypeStockUpdated, itemID, StockUpdatedPayload{
+		ItemID:           itemID,
+		PreviousQuantity: previousQuantity,
+		NewQuantity:      newQuantity,
+		Delta:            delta,
+		Reason:           reason,
+	})
+}
 
-	return p.PublishEvent("inventory.deleted", body)
+// PublishRaw wraps an already-serialized payload (e.g. an outbox row's
+// JSON body) in a CloudEvent and publishes it on routingKey, without
+// re-marshaling it into a new payload shape. Used by OutboxDispatcher,
+// which only has the raw bytes a repo enqueued, not the typed payload.
+func (p *Publisher) PublishRaw(ctx context.Context, routingKey, subject string, data json.RawMessage) error {
+	return p.publish(ctx, routingKey, subject, data)
 }
 
-func (p *Publisher) Close() {
-	if p.channel != nil {
-		p.channel.Close()
-	}
-	if p.conn != nil {
-		p.conn.Close()
+// IsHealthy reports whether the underlying broker connection is usable.
+func (p *Publisher) IsHealthy() bool {
+	return p.broker != nil && p.broker.IsHealthy()
+}
+
+// Close releases the underlying broker connection.
+func (p *Publisher) Close() error {
+	if p.broker != nil {
+		return p.broker.Close()
 	}
+	return nil
 }
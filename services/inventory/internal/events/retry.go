@@ -0,0 +1,70 @@
+package events
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryPolicy controls how many times Consumer retries a handler failure
+// and how long it waits between attempts before giving up on a message
+// and routing it to the dead-letter queue.
+//
+// Each entry in Delays is the wait before the Nth redelivery, so
+// {5s, 30s, 5m} gives a failing message three more chances, spaced
+// further apart each time, before it's dead-lettered.
+type RetryPolicy struct {
+	Delays []time.Duration
+}
+
+// DefaultRetryPolicy waits 5s, then 30s, then 5m between retries. It's
+// the policy every service wires up unless it has a reason to tune the
+// spacing or attempt count.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Delays: []time.Duration{5 * time.Second, 30 * time.Second, 5 * time.Minute}}
+}
+
+// maxAttempts is the total number of times a message's handler runs
+// before p gives up: the initial delivery plus one retry per delay.
+func (p RetryPolicy) maxAttempts() int {
+	return len(p.Delays) + 1
+}
+
+// delayFor returns the TTL to apply before redelivering a message on its
+// attempt'th retry (0-indexed), and whether attempt is still within the
+// policy (false once attempts are exhausted and the message should be
+// dead-lettered instead).
+func (p RetryPolicy) delayFor(attempt int) (time.Duration, bool) {
+	if attempt < 0 || attempt >= len(p.Delays) {
+		return 0, false
+	}
+	return p.Delays[attempt], true
+}
+
+// dlxExchangeName is the dead-letter exchange the retry queue forwards
+// expired messages through on their way back to ExchangeName.
+const dlxExchangeName = "bookstore.events.dlx"
+
+// retryQueueName is the single queue every redelivery of every routing
+// key waits in; the wait itself comes from the per-message "expiration"
+// field (see Consumer.scheduleRetry), not a queue-wide TTL, so one queue
+// serves every attempt and every event type.
+func retryQueueName(serviceName string) string {
+	return fmt.Sprintf("%s.inventory.retry", serviceName)
+}
+
+// dlqQueueName is where a copy of a message lands once it has exhausted
+// RetryPolicy, for operators working directly against RabbitMQ.
+// GET /admin/dlq and POST /admin/dlq/{id}/replay read the
+// consumer_dead_letters table instead, not this queue.
+func dlqQueueName(serviceName string) string {
+	return fmt.Sprintf("%s.inventory.dlq", serviceName)
+}
+
+// retryCountHeader is the application header Consumer stamps on a
+// message each time it's handed to the retry queue, so the next
+// delivery knows how many attempts have already been made. It is
+// deliberately a plain application header rather than relying on
+// RabbitMQ's automatic "x-death" array, which would also work but
+// requires parsing a less predictable nested table shape for no benefit
+// here.
+const retryCountHeader = "x-retry-count"
@@ -0,0 +1,29 @@
+package events
+
+import amqp "github.com/rabbitmq/amqp091-go"
+
+// amqpHeaderCarrier adapts amqp.Table to otel's propagation.TextMapCarrier
+// so trace context can be injected into (and extracted from) the same
+// Headers map that already carries specversion/type.
+type amqpHeaderCarrier amqp.Table
+
+func (c amqpHeaderCarrier) Get(key string) string {
+	value, ok := c[key]
+	if !ok {
+		return ""
+	}
+	s, _ := value.(string)
+	return s
+}
+
+func (c amqpHeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c amqpHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
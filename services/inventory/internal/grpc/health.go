@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/bookstore/inventory/internal/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// HealthServer implements the gRPC health checking protocol on top of a
+// health.Monitor, which probes dependencies in the background so Check
+// and Watch only ever report a cached status instead of re-pinging
+// inline on every call.
+type HealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	monitor *health.Monitor
+}
+
+// NewHealthServer creates a new health check server backed by monitor.
+func NewHealthServer(monitor *health.Monitor) *HealthServer {
+	return &HealthServer{monitor: monitor}
+}
+
+func toHealthCheckStatus(status health.Status) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	switch status {
+	case health.StatusServing:
+		return grpc_health_v1.HealthCheckResponse_SERVING
+	case health.StatusNotServing:
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	default:
+		return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+	}
+}
+
+// Check implements the unary health check
+func (h *HealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	status, ok := h.monitor.Status(req.Service)
+	if !ok {
+		return &grpc_health_v1.HealthCheckResponse{
+			Status: grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+		}, nil
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{
+		Status: toHealthCheckStatus(status),
+	}, nil
+}
+
+// Watch implements streaming health check watching: it sends req.Service's
+// current status immediately, then a new HealthCheckResponse every time
+// the monitor observes a transition, until the client disconnects.
+func (h *HealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, server grpc_health_v1.Health_WatchServer) error {
+	ch, current, ok := func() (<-chan health.Status, health.Status, bool) {
+		ch, current, ok, unsubscribe := h.monitor.Watch(req.Service)
+		if ok {
+			go func() {
+				<-server.Context().Done()
+				unsubscribe()
+			}()
+		}
+		return ch, current, ok
+	}()
+
+	if !ok {
+		return server.Send(&grpc_health_v1.HealthCheckResponse{
+			Status: grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+		})
+	}
+
+	if err := server.Send(&grpc_health_v1.HealthCheckResponse{
+		Status: toHealthCheckStatus(current),
+	}); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-server.Context().Done():
+			return server.Context().Err()
+		case status, open := <-ch:
+			if !open {
+				return nil
+			}
+			if err := server.Send(&grpc_health_v1.HealthCheckResponse{
+				Status: toHealthCheckStatus(status),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
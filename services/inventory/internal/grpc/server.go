@@ -2,28 +2,54 @@ package grpc
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"log"
-	"time"
 
 	inventorypb "github.com/bookstore/contracts/gen/go/inventory"
 	"github.com/bookstore/inventory/internal/db"
 	"github.com/bookstore/inventory/internal/events"
+	"github.com/bookstore/inventory/internal/health"
 	"github.com/bookstore/inventory/internal/repo"
+	"github.com/bookstore/inventory/pkg/observability"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
+// idempotencyKeyHeader is the gRPC metadata key a caller sets to make a
+// ReserveStock/ReleaseStock/UpdateStock call safe to retry; see
+// idempotencyKey.
+const idempotencyKeyHeader = "x-idempotency-key"
+
+// idempotencyKey returns the caller-supplied x-idempotency-key header, or
+// fallback if the header is absent, so callers that don't set it (e.g.
+// older clients) still get a stable per-request key instead of none at
+// all.
+func idempotencyKey(ctx context.Context, fallback string) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(idempotencyKeyHeader); len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return fallback
+}
+
 type Server struct {
 	inventorypb.UnimplementedInventoryServiceServer
 	repo      *repo.InventoryRepo
-	publisher *events.Publisher
+	publisher events.EventPublisher
 }
 
-func NewServer(repository *repo.InventoryRepo, publisher *events.Publisher) *grpc.Server {
-	grpcServer := grpc.NewServer()
+func NewServer(repository *repo.InventoryRepo, publisher events.EventPublisher, monitor *health.Monitor) *grpc.Server {
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(observability.UnaryServerInterceptor()),
+	)
 
 	svc := &Server{
 		repo:      repository,
@@ -31,12 +57,13 @@ func NewServer(repository *repo.InventoryRepo, publisher *events.Publisher) *grp
 	}
 
 	inventorypb.RegisterInventoryServiceServer(grpcServer, svc)
+	grpc_health_v1.RegisterHealthServer(grpcServer, NewHealthServer(monitor))
 
 	return grpcServer
 }
 
 func (s *Server) GetItem(ctx context.Context, req *inventorypb.GetItemRequest) (*inventorypb.GetItemResponse, error) {
-	item, err := s.repo.GetItemByID(req.ItemId)
+	item, err := s.repo.GetItemByID(ctx, req.ItemId)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to get item: %v", err)
 	}
@@ -57,7 +84,7 @@ func (s *Server) GetItem(ctx context.Context, req *inventorypb.GetItemRequest) (
 }
 
 func (s *Server) CheckAvailability(ctx context.Context, req *inventorypb.CheckAvailabilityRequest) (*inventorypb.CheckAvailabilityResponse, error) {
-	item, err := s.repo.GetItemByID(req.ItemId)
+	item, err := s.repo.GetItemByID(ctx, req.ItemId)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to check availability: %v", err)
 	}
@@ -83,6 +110,14 @@ func (s *Server) CheckAvailability(ctx context.Context, req *inventorypb.CheckAv
 	}, nil
 }
 
+// reserveStockResult is the shape persisted for a ReserveStock
+// idempotency key, so a retried call can be answered from the stored
+// JSON instead of reserving stock twice.
+type reserveStockResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
 func (s *Server) ReserveStock(ctx context.Context, req *inventorypb.ReserveStockRequest) (*inventorypb.ReserveStockResponse, error) {
 	reserved := make([]db.ReservedItem, 0, len(req.Items))
 	for _, ri := range req.Items {
@@ -92,40 +127,44 @@ func (s *Server) ReserveStock(ctx context.Context, req *inventorypb.ReserveStock
 		})
 	}
 
-	if err := s.repo.ReserveStock(req.OrderId, reserved); err != nil {
+	key := idempotencyKey(ctx, req.OrderId+":reserve")
+	responseBytes, err := s.repo.WithIdempotency(ctx, key, "ReserveStock", req, func(tx *sql.Tx) (interface{}, error) {
+		// Propagate ReserveStockTx's error instead of folding it into a
+		// Success:false result: returning it here is what makes
+		// WithIdempotency roll back the transaction, so a partially
+		// reserved order never gets its idempotency key permanently
+		// claimed against a corrupt on-hand count.
+		if err := repo.ReserveStockTx(tx, req.OrderId, reserved); err != nil {
+			return nil, fmt.Errorf("failed to reserve stock: %w", err)
+		}
+		return reserveStockResult{Success: true, Message: "Stock reserved successfully"}, nil
+	})
+	if err != nil {
 		log.Printf(" Failed to reserve stock for order %s: %v", req.OrderId, err)
-		return &inventorypb.ReserveStockResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to reserve stock: %v", err),
-		}, nil
+		return nil, status.Errorf(codes.Internal, "failed to reserve stock: %v", err)
 	}
 
-	// Publish stock reserved event
-	if s.publisher != nil {
-		event := events.StockReservedEvent{
-			EventID:      fmt.Sprintf("inv-%d", time.Now().UnixNano()),
-			EventType:    "inventory.stock_reserved",
-			EventVersion: "1.0.0",
-			Timestamp:    time.Now().Format(time.RFC3339),
-			Payload: events.StockReservedPayload{
-				OrderID: req.OrderId,
-				Items:   reserved,
-			},
-		}
-
-		data, _ := json.Marshal(event)
-		if err := s.publisher.PublishEvent("inventory.stock_reserved", data); err != nil {
-			log.Printf("  Failed to publish stock reserved event: %v", err)
-		}
+	var result reserveStockResult
+	if err := json.Unmarshal(responseBytes, &result); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode reserve stock result: %v", err)
 	}
 
+	// ReserveStock wrote a stock reserved event into the outbox in the same
+	// transaction as the stock mutation; OutboxDispatcher delivers it.
 	log.Printf(" Stock reserved for order %s", req.OrderId)
 	return &inventorypb.ReserveStockResponse{
-		Success: true,
-		Message: "Stock reserved successfully",
+		Success: result.Success,
+		Message: result.Message,
 	}, nil
 }
 
+// releaseStockResult is the shape persisted for a ReleaseStock
+// idempotency key; see reserveStockResult.
+type releaseStockResult struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
 func (s *Server) ReleaseStock(ctx context.Context, req *inventorypb.ReleaseStockRequest) (*inventorypb.ReleaseStockResponse, error) {
 	reserved := make([]db.ReservedItem, 0, len(req.Items))
 	for _, ri := range req.Items {
@@ -135,71 +174,66 @@ func (s *Server) ReleaseStock(ctx context.Context, req *inventorypb.ReleaseStock
 		})
 	}
 
-	if err := s.repo.ReleaseStock(req.OrderId, reserved); err != nil {
+	key := idempotencyKey(ctx, req.OrderId+":release")
+	responseBytes, err := s.repo.WithIdempotency(ctx, key, "ReleaseStock", req, func(tx *sql.Tx) (interface{}, error) {
+		// See ReserveStock: propagate the error so WithIdempotency rolls
+		// back instead of committing a claimed idempotency key against a
+		// release that never actually happened.
+		if err := repo.ReleaseStockTx(tx, req.OrderId, reserved); err != nil {
+			return nil, fmt.Errorf("failed to release stock: %w", err)
+		}
+		return releaseStockResult{Success: true, Message: "Stock released successfully"}, nil
+	})
+	if err != nil {
 		log.Printf(" Failed to release stock for order %s: %v", req.OrderId, err)
-		return &inventorypb.ReleaseStockResponse{
-			Success: false,
-			Message: fmt.Sprintf("Failed to release stock: %v", err),
-		}, nil
+		return nil, status.Errorf(codes.Internal, "failed to release stock: %v", err)
 	}
 
-	// Publish stock released event
-	if s.publisher != nil {
-		event := events.StockReleasedEvent{
-			EventID:      fmt.Sprintf("inv-%d", time.Now().UnixNano()),
-			EventType:    "inventory.stock_released",
-			EventVersion: "1.0.0",
-			Timestamp:    time.Now().Format(time.RFC3339),
-			Payload: events.StockReleasedPayload{
-				OrderID: req.OrderId,
-				Items:   reserved,
-			},
-		}
-
-		data, _ := json.Marshal(event)
-		if err := s.publisher.PublishEvent("inventory.stock_released", data); err != nil {
-			log.Printf("  Failed to publish stock released event: %v", err)
-		}
+	var result releaseStockResult
+	if err := json.Unmarshal(responseBytes, &result); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode release stock result: %v", err)
 	}
 
+	// ReleaseStock wrote a stock released event into the outbox in the same
+	// transaction as the stock mutation; OutboxDispatcher delivers it.
 	log.Printf(" Stock released for order %s", req.OrderId)
 	return &inventorypb.ReleaseStockResponse{
-		Success: true,
-		Message: "Stock released successfully",
+		Success: result.Success,
+		Message: result.Message,
 	}, nil
 }
 
+// updateStockResult is the shape persisted for an UpdateStock
+// idempotency key; see reserveStockResult.
+type updateStockResult struct {
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+	NewQuantity int32  `json:"new_quantity"`
+}
+
 func (s *Server) UpdateStock(ctx context.Context, req *inventorypb.UpdateStockRequest) (*inventorypb.UpdateStockResponse, error) {
-	newQuantity, err := s.repo.UpdateStock(req.ItemId, req.Delta)
+	key := idempotencyKey(ctx, fmt.Sprintf("%s:update:%d", req.ItemId, req.Delta))
+	responseBytes, err := s.repo.WithIdempotency(ctx, key, "UpdateStock", req, func(tx *sql.Tx) (interface{}, error) {
+		newQuantity, err := repo.UpdateStockTx(tx, req.ItemId, req.Delta)
+		if err != nil {
+			return nil, err
+		}
+		return updateStockResult{Success: true, Message: "Stock updated successfully", NewQuantity: newQuantity}, nil
+	})
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to update stock: %v", err)
 	}
 
-	// Publish stock updated event
-	if s.publisher != nil {
-		event := events.StockUpdatedEvent{
-			EventID:      fmt.Sprintf("inv-%d", time.Now().UnixNano()),
-			EventType:    "inventory.stock_updated",
-			EventVersion: "1.0.0",
-			Timestamp:    time.Now().Format(time.RFC3339),
-			Payload: events.StockUpdatedPayload{
-				ItemID:           req.ItemId,
-				PreviousQuantity: newQuantity - req.Delta,
-				NewQuantity:      newQuantity,
-				Delta:            req.Delta,
-				Reason:           "Manual update",
-			},
-		}
-
-		data, _ := json.Marshal(event)
-		if err := s.publisher.PublishEvent("inventory.stock_updated", data); err != nil {
-			log.Printf("  Failed to publish stock updated event: %v", err)
-		}
+	var result updateStockResult
+	if err := json.Unmarshal(responseBytes, &result); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to decode update stock result: %v", err)
 	}
 
+	// UpdateStock wrote a stock updated event into the outbox in the same
+	// transaction as the stock mutation; OutboxDispatcher delivers it.
 	return &inventorypb.UpdateStockResponse{
-		Success:     true,
-		Message:     "Stock updated successfully",
-		NewQuantity: newQuantity,
+		Success:     result.Success,
+		Message:     result.Message,
+		NewQuantity: result.NewQuantity,
 	}, nil
 }
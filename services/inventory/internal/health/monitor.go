@@ -0,0 +1,239 @@
+// Package health implements a small dependency-probing monitor shared by
+// the gRPC health service and the HTTP /livez, /readyz, and /startupz
+// endpoints, so all of them report the same, periodically-refreshed view
+// of the service's dependencies instead of pinging them inline on every
+// request.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the coalesced health of one registered check.
+type Status int
+
+const (
+	// StatusUnknown is returned for a name that was never registered.
+	StatusUnknown Status = iota
+	StatusServing
+	StatusNotServing
+)
+
+// CheckFunc probes a single dependency, returning a non-nil error if it
+// is unavailable.
+type CheckFunc func() error
+
+// watcher is one subscriber's channel, buffered by one so Probe never
+// blocks on a slow or gone watcher; a skipped transition is harmless
+// since Watch always sends the latest status on (re)subscribe.
+type watcher struct {
+	ch chan Status
+}
+
+// Monitor runs registered checks on a timer, coalesces their results per
+// name, and fans out status transitions to any number of concurrent
+// watchers. name "" is the overall server status, matching the
+// grpc_health_v1 convention that an empty service name means "the whole
+// server".
+//
+// A dependency only flips from StatusServing to StatusNotServing after
+// failureThreshold consecutive failed probes, so a single transient
+// Postgres or RabbitMQ hiccup between probe ticks doesn't flip /readyz
+// (and therefore doesn't get the pod killed by an impatient orchestrator)
+// the instant it happens.
+type Monitor struct {
+	mu                  sync.Mutex
+	checks              map[string]CheckFunc
+	statuses            map[string]Status
+	watchers            map[string][]*watcher
+	failureThreshold    int
+	consecutiveFailures map[string]int
+	started             bool
+}
+
+// NewMonitor returns an empty Monitor; register checks with RegisterCheck
+// before calling Run. failureThreshold is the number of consecutive
+// failed probes a check must accumulate before it flips to
+// StatusNotServing; values less than 1 are treated as 1 (flip
+// immediately, the previous behavior).
+func NewMonitor(failureThreshold int) *Monitor {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	return &Monitor{
+		checks:              make(map[string]CheckFunc),
+		statuses:            make(map[string]Status),
+		watchers:            make(map[string][]*watcher),
+		failureThreshold:    failureThreshold,
+		consecutiveFailures: make(map[string]int),
+	}
+}
+
+// RegisterCheck adds a named dependency probe. Registering "" overrides
+// the default overall status, which is otherwise derived as NOT_SERVING
+// if any other registered check is failing.
+func (m *Monitor) RegisterCheck(name string, check CheckFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.checks[name] = check
+	if _, ok := m.statuses[name]; !ok {
+		m.statuses[name] = StatusUnknown
+	}
+}
+
+// Run probes every registered check every interval until ctx is
+// cancelled. It probes once immediately so Status/Watch have a result
+// before the first tick.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	m.Probe()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Probe()
+		}
+	}
+}
+
+// Probe runs every registered check once and notifies watchers of any
+// name whose coalesced status changed.
+func (m *Monitor) Probe() {
+	m.mu.Lock()
+	checks := make(map[string]CheckFunc, len(m.checks))
+	for name, check := range m.checks {
+		checks[name] = check
+	}
+	m.mu.Unlock()
+
+	results := make(map[string]Status, len(checks))
+	overallFailing := false
+	for name, check := range checks {
+		if name == "" {
+			continue // "" is derived below unless explicitly registered
+		}
+		results[name] = m.evaluate(name, check())
+		if results[name] == StatusNotServing {
+			overallFailing = true
+		}
+	}
+
+	if overallCheck, ok := checks[""]; ok {
+		results[""] = m.evaluate("", overallCheck())
+	} else if overallFailing {
+		results[""] = StatusNotServing
+	} else {
+		results[""] = StatusServing
+	}
+
+	for name, status := range results {
+		m.setStatus(name, status)
+	}
+}
+
+// evaluate folds one check's raw error into a Status, only returning
+// StatusNotServing once name has failed failureThreshold times in a row;
+// a single success resets the streak immediately.
+func (m *Monitor) evaluate(name string, err error) Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err == nil {
+		m.consecutiveFailures[name] = 0
+		return StatusServing
+	}
+
+	m.consecutiveFailures[name]++
+	if m.consecutiveFailures[name] >= m.failureThreshold {
+		return StatusNotServing
+	}
+	// Below threshold: keep reporting the last-known-good status instead
+	// of flapping readiness on a single missed probe.
+	if status, ok := m.statuses[name]; ok && status != StatusUnknown {
+		return status
+	}
+	return StatusServing
+}
+
+// setStatus updates name's cached status and, if it changed, notifies
+// every watcher subscribed to it.
+func (m *Monitor) setStatus(name string, status Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.statuses[name] == status {
+		return
+	}
+	m.statuses[name] = status
+
+	for _, w := range m.watchers[name] {
+		select {
+		case w.ch <- status:
+		default:
+			// Watcher hasn't drained the previous transition yet; it will
+			// still observe the latest status via Watch's initial send.
+		}
+	}
+}
+
+// Status returns name's last known status and whether name has ever been
+// registered (directly, or implicitly via the "" aggregate).
+func (m *Monitor) Status(name string) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, ok := m.statuses[name]
+	return status, ok
+}
+
+// MarkStarted records that migrations and the first dependency probe
+// have completed, so /startupz can stop returning 503. It is idempotent.
+func (m *Monitor) MarkStarted() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.started = true
+}
+
+// Started reports whether MarkStarted has been called, for /startupz.
+func (m *Monitor) Started() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.started
+}
+
+// Watch subscribes to status transitions for name. It returns the
+// channel, name's current status, whether name is registered, and an
+// unsubscribe func the caller must invoke when done watching.
+func (m *Monitor) Watch(name string) (ch <-chan Status, current Status, ok bool, unsubscribe func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok = m.statuses[name]
+	if !ok {
+		return nil, StatusUnknown, false, func() {}
+	}
+
+	w := &watcher{ch: make(chan Status, 1)}
+	m.watchers[name] = append(m.watchers[name], w)
+
+	unsubscribe = func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		subs := m.watchers[name]
+		for i, sub := range subs {
+			if sub == w {
+				m.watchers[name] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(w.ch)
+	}
+
+	return w.ch, current, true, unsubscribe
+}
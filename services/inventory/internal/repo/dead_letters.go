@@ -0,0 +1,83 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ConsumerDeadLetter is a message events.Consumer gave up on after
+// exhausting its RetryPolicy, kept around for operator inspection and
+// replay via the /admin/dlq endpoints.
+type ConsumerDeadLetter struct {
+	ID             int64
+	MessageID      string
+	RoutingKey     string
+	Body           json.RawMessage
+	Attempts       int32
+	LastError      string
+	DeadLetteredAt time.Time
+}
+
+// InsertConsumerDeadLetter records a message Consumer could not process,
+// returning the row's id for later lookup/replay.
+func (r *InventoryRepo) InsertConsumerDeadLetter(ctx context.Context, messageID, routingKey string, body json.RawMessage, attempts int32, lastErr string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO consumer_dead_letters (message_id, routing_key, body, attempts, last_error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		messageID, routingKey, body, attempts, lastErr,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert consumer dead letter: %w", err)
+	}
+	return id, nil
+}
+
+// ListConsumerDeadLetters returns every dead-lettered message, newest
+// first, for the admin inspection endpoint.
+func (r *InventoryRepo) ListConsumerDeadLetters(ctx context.Context) ([]ConsumerDeadLetter, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, message_id, routing_key, body, attempts, last_error, dead_lettered_at
+		FROM consumer_dead_letters
+		ORDER BY id DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list consumer dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []ConsumerDeadLetter
+	for rows.Next() {
+		var dl ConsumerDeadLetter
+		if err := rows.Scan(&dl.ID, &dl.MessageID, &dl.RoutingKey, &dl.Body, &dl.Attempts, &dl.LastError, &dl.DeadLetteredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan consumer dead letter: %w", err)
+		}
+		letters = append(letters, dl)
+	}
+	return letters, rows.Err()
+}
+
+// GetConsumerDeadLetter looks up a single dead-lettered message by id,
+// for replay.
+func (r *InventoryRepo) GetConsumerDeadLetter(ctx context.Context, id int64) (*ConsumerDeadLetter, error) {
+	var dl ConsumerDeadLetter
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, message_id, routing_key, body, attempts, last_error, dead_lettered_at
+		FROM consumer_dead_letters WHERE id = $1`, id,
+	).Scan(&dl.ID, &dl.MessageID, &dl.RoutingKey, &dl.Body, &dl.Attempts, &dl.LastError, &dl.DeadLetteredAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer dead letter %d: %w", id, err)
+	}
+	return &dl, nil
+}
+
+// DeleteConsumerDeadLetter removes a dead-lettered message after it has
+// been replayed.
+func (r *InventoryRepo) DeleteConsumerDeadLetter(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM consumer_dead_letters WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete consumer dead letter %d: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultIdempotencyTTL is how long an idempotency key is remembered
+// before CleanExpiredIdempotencyKeys is free to delete it.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ErrIdempotencyKeyConflict is returned when key was already used for a
+// request with a different method or body, so replaying it would be
+// unsafe.
+var ErrIdempotencyKeyConflict = fmt.Errorf("idempotency key reused for a different request")
+
+// ErrIdempotencyKeyInProgress is returned when key claimed a request
+// that has not finished (or crashed before storing a response); callers
+// should retry rather than treat this as success or failure.
+var ErrIdempotencyKeyInProgress = fmt.Errorf("a request with this idempotency key is already in progress")
+
+// WithIdempotency runs fn at most once for key: on the first call it
+// claims key inside a transaction, runs fn within that same
+// transaction, stores fn's result as the key's response, and commits.
+// On a repeat call with the same key it skips fn entirely and returns
+// the previously stored response, so a retried saga step (e.g. a
+// compensable ReserveStock/ReleaseStock RPC) never double-applies its
+// mutation. req is hashed and compared against the stored request so a
+// key reused with a different body is rejected instead of silently
+// returning the wrong response.
+func (r *InventoryRepo) WithIdempotency(ctx context.Context, key, method string, req interface{}, fn func(tx *sql.Tx) (interface{}, error)) (json.RawMessage, error) {
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency request: %w", err)
+	}
+	reqHash := sha256.Sum256(reqBytes)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// expiresAt is computed in Go rather than via now() + interval
+	// arithmetic in SQL, so this insert runs unchanged against both
+	// Postgres and SQLite (e.g. in tests).
+	expiresAt := time.Now().Add(DefaultIdempotencyTTL)
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (key, method, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (key) DO NOTHING`,
+		key, method, reqHash[:], expiresAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to claim idempotency key %s: %w", key, err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if claimed == 0 {
+		// Someone already claimed this key; the transaction we opened
+		// made no changes, so just roll it back and inspect the
+		// existing row instead of retrying fn.
+		tx.Rollback()
+		return r.loadIdempotentResponse(ctx, key, method, reqHash[:])
+	}
+
+	out, err := fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	responseBytes, err := json.Marshal(out)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE idempotency_keys SET response_bytes = $1 WHERE key = $2`,
+		responseBytes, key,
+	); err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to store idempotent response for key %s: %w", key, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return responseBytes, nil
+}
+
+func (r *InventoryRepo) loadIdempotentResponse(ctx context.Context, key, method string, reqHash []byte) (json.RawMessage, error) {
+	var storedMethod string
+	var storedHash, storedResponse []byte
+	err := r.db.QueryRowContext(ctx,
+		`SELECT method, request_hash, response_bytes FROM idempotency_keys WHERE key = $1`, key,
+	).Scan(&storedMethod, &storedHash, &storedResponse)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key %s: %w", key, err)
+	}
+
+	if storedMethod != method || !bytes.Equal(storedHash, reqHash) {
+		return nil, ErrIdempotencyKeyConflict
+	}
+	if storedResponse == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+	return storedResponse, nil
+}
+
+// CleanExpiredIdempotencyKeys deletes every idempotency key past its
+// expires_at, so the table does not grow unbounded.
+func (r *InventoryRepo) CleanExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean expired idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RunIdempotencyKeyCleaner deletes expired idempotency keys every
+// pollInterval until ctx is cancelled.
+func (r *InventoryRepo) RunIdempotencyKeyCleaner(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := r.CleanExpiredIdempotencyKeys(ctx); err != nil {
+				log.Printf("  Failed to clean expired idempotency keys: %v", err)
+			} else if n > 0 {
+				log.Printf("Cleaned %d expired idempotency keys", n)
+			}
+		}
+	}
+}
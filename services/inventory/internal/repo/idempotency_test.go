@@ -0,0 +1,147 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bookstore/inventory/internal/db"
+)
+
+// setupTestRepo opens an in-memory SQLite database, migrated with the
+// same schema as production, and wraps it in an InventoryRepo. SQLite
+// gives every new connection its own empty :memory: database, so the
+// pool is capped at one connection to keep the repo and the test on the
+// same one.
+func setupTestRepo(t *testing.T) *InventoryRepo {
+	t.Helper()
+
+	primary, _, err := db.Connect(db.Config{Driver: "sqlite", PrimaryDSN: ":memory:"})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	primary.SetMaxOpenConns(1)
+
+	if err := db.RunMigrations(primary); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	return NewInventoryRepo(primary)
+}
+
+func TestWithIdempotencyRunsFnOnce(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	calls := 0
+	fn := func(tx *sql.Tx) (interface{}, error) {
+		calls++
+		return map[string]string{"status": "reserved"}, nil
+	}
+
+	resp1, err := repo.WithIdempotency(ctx, "key-1", "ReserveStock", map[string]string{"order_id": "ORDER-1"}, fn)
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	resp2, err := repo.WithIdempotency(ctx, "key-1", "ReserveStock", map[string]string{"order_id": "ORDER-1"}, fn)
+	if err != nil {
+		t.Fatalf("replayed call: %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected fn to run once, ran %d times", calls)
+	}
+	if string(resp1) != string(resp2) {
+		t.Fatalf("replayed response %s does not match original %s", resp2, resp1)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal(resp2, &got); err != nil {
+		t.Fatalf("failed to unmarshal stored response: %v", err)
+	}
+	if got["status"] != "reserved" {
+		t.Fatalf("unexpected stored response: %v", got)
+	}
+}
+
+func TestWithIdempotencyConflictingRequestErrors(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	fn := func(tx *sql.Tx) (interface{}, error) {
+		return map[string]string{"status": "reserved"}, nil
+	}
+
+	_, err := repo.WithIdempotency(ctx, "key-2", "ReserveStock", map[string]string{"order_id": "ORDER-1"}, fn)
+	if err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+
+	_, err = repo.WithIdempotency(ctx, "key-2", "ReserveStock", map[string]string{"order_id": "ORDER-2"}, fn)
+	if err != ErrIdempotencyKeyConflict {
+		t.Fatalf("expected ErrIdempotencyKeyConflict, got %v", err)
+	}
+}
+
+func TestWithIdempotencyFnErrorDoesNotClaimKey(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	wantErr := sql.ErrTxDone
+	calls := 0
+	failing := func(tx *sql.Tx) (interface{}, error) {
+		calls++
+		return nil, wantErr
+	}
+
+	_, err := repo.WithIdempotency(ctx, "key-3", "ReserveStock", map[string]string{"order_id": "ORDER-1"}, failing)
+	if err != wantErr {
+		t.Fatalf("expected %v, got %v", wantErr, err)
+	}
+
+	succeeding := func(tx *sql.Tx) (interface{}, error) {
+		calls++
+		return map[string]string{"status": "reserved"}, nil
+	}
+	if _, err := repo.WithIdempotency(ctx, "key-3", "ReserveStock", map[string]string{"order_id": "ORDER-1"}, succeeding); err != nil {
+		t.Fatalf("retry after failed fn: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected fn to run twice (failed attempt + retry), ran %d times", calls)
+	}
+}
+
+func TestCleanExpiredIdempotencyKeys(t *testing.T) {
+	repo := setupTestRepo(t)
+	ctx := context.Background()
+
+	if _, err := repo.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (key, method, request_hash, expires_at) VALUES ($1, $2, $3, $4)`,
+		"expired-key", "ReserveStock", []byte("hash"), time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("failed to seed expired key: %v", err)
+	}
+
+	fn := func(tx *sql.Tx) (interface{}, error) { return "ok", nil }
+	if _, err := repo.WithIdempotency(ctx, "live-key", "ReserveStock", "req", fn); err != nil {
+		t.Fatalf("failed to create live key: %v", err)
+	}
+
+	deleted, err := repo.CleanExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		t.Fatalf("CleanExpiredIdempotencyKeys: %v", err)
+	}
+	if deleted != 1 {
+		t.Fatalf("expected 1 key deleted, got %d", deleted)
+	}
+
+	var remaining int
+	if err := repo.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM idempotency_keys`).Scan(&remaining); err != nil {
+		t.Fatalf("failed to count remaining keys: %v", err)
+	}
+	if remaining != 1 {
+		t.Fatalf("expected 1 key remaining, got %d", remaining)
+	}
+}
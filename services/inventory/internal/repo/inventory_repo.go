@@ -1,55 +1,124 @@
 package repo
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/bookstore/inventory/internal/db"
 )
 
+// primaryKeyType is an unexported context key so only WithPrimary can set it.
+type primaryKeyType struct{}
+
+// WithPrimary forces reads made with the returned context to hit the
+// primary instead of a replica, for read-your-writes right after a
+// mutation (e.g. re-reading an item just after UpdateStock).
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKeyType{}, true)
+}
+
+func wantsPrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKeyType{}).(bool)
+	return forced
+}
+
 type InventoryRepo struct {
-	db *sql.DB
+	db       *sql.DB // primary: all writes, migrations, and reads without a replica or under WithPrimary
+	replicas []*sql.DB
+	next     uint64
 }
 
-func NewInventoryRepo(database *sql.DB) *InventoryRepo {
-	return &InventoryRepo{db: database}
+// NewInventoryRepo returns a repo backed by primary for writes and,
+// if any are given, round-robins reads across replicas.
+func NewInventoryRepo(primary *sql.DB, replicas ...*sql.DB) *InventoryRepo {
+	return &InventoryRepo{db: primary, replicas: replicas}
 }
 
+// reader picks the *sql.DB a read should run against: the primary if
+// there are no replicas or ctx was created with WithPrimary, otherwise
+// the next replica in round-robin order.
+func (r *InventoryRepo) reader(ctx context.Context) *sql.DB {
+	if len(r.replicas) == 0 || wantsPrimary(ctx) {
+		return r.db
+	}
+	n := atomic.AddUint64(&r.next, 1)
+	return r.replicas[n%uint64(len(r.replicas))]
+}
+
+// CreateItem inserts item and enqueues its inventory.created outbox event
+// in the same transaction, so a crash between the insert and the publish
+// (previously done inline by events.Consumer) can't leave an item with no
+// created event ever emitted.
 func (r *InventoryRepo) CreateItem(item *db.Item) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := createItemTx(tx, item); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func createItemTx(tx *sql.Tx, item *db.Item) error {
 	query := `
 		INSERT INTO items (item_id, name, category, quantity, price)
 		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (item_id) DO NOTHING`
-	
-	_, err := r.db.Exec(query, item.ItemID, item.Name, item.Category, item.Quantity, item.Price)
-	if err != nil {
+
+	if _, err := tx.Exec(query, item.ItemID, item.Name, item.Category, item.Quantity, item.Price); err != nil {
 		return fmt.Errorf("failed to create item: %w", err)
 	}
-	return nil
+
+	return enqueueOutbox(tx, item.ItemID, outboxEventItemCreated, itemCreatedOutboxPayload{
+		ItemID:   item.ItemID,
+		Name:     item.Name,
+		Category: item.Category,
+		Quantity: item.Quantity,
+	})
 }
 
+// DeleteItem removes itemID and enqueues its inventory.deleted outbox
+// event in the same transaction; see CreateItem.
 func (r *InventoryRepo) DeleteItem(itemID string) error {
-	query := `DELETE FROM items WHERE item_id = $1`
-	result, err := r.db.Exec(query, itemID)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := deleteItemTx(tx, itemID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteItemTx(tx *sql.Tx, itemID string) error {
+	result, err := tx.Exec(`DELETE FROM items WHERE item_id = $1`, itemID)
 	if err != nil {
 		return fmt.Errorf("failed to delete item: %w", err)
 	}
-	
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-	
 	if rowsAffected == 0 {
 		return fmt.Errorf("item not found: %s", itemID)
 	}
-	
-	return nil
+
+	return enqueueOutbox(tx, itemID, outboxEventItemDeleted, itemDeletedOutboxPayload{ItemID: itemID})
 }
 
-func (r *InventoryRepo) GetItemByID(itemID string) (*db.Item, error) {
+func (r *InventoryRepo) GetItemByID(ctx context.Context, itemID string) (*db.Item, error) {
 	query := `SELECT item_id, name, category, quantity, price FROM items WHERE item_id = $1`
-	row := r.db.QueryRow(query, itemID)
+	row := r.reader(ctx).QueryRow(query, itemID)
 
 	var item db.Item
 	if err := row.Scan(&item.ItemID, &item.Name, &item.Category, &item.Quantity, &item.Price); err != nil {
@@ -61,8 +130,8 @@ func (r *InventoryRepo) GetItemByID(itemID string) (*db.Item, error) {
 	return &item, nil
 }
 
-func (r *InventoryRepo) GetAllItems() ([]db.Item, error) {
-	rows, err := r.db.Query(`SELECT item_id, name, category, quantity, price FROM items`)
+func (r *InventoryRepo) GetAllItems(ctx context.Context) ([]db.Item, error) {
+	rows, err := r.reader(ctx).Query(`SELECT item_id, name, category, quantity, price FROM items`)
 	if err != nil {
 		return nil, err
 	}
@@ -80,12 +149,49 @@ func (r *InventoryRepo) GetAllItems() ([]db.Item, error) {
 }
 
 func (r *InventoryRepo) UpdateStock(itemID string, delta int32) (int32, error) {
-	var newQuantity int32
-	query := `UPDATE items SET quantity = quantity + $1 WHERE item_id = $2 RETURNING quantity`
-	err := r.db.QueryRow(query, delta, itemID).Scan(&newQuantity)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return 0, err
 	}
+
+	newQuantity, err := UpdateStockTx(tx, itemID, delta)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return newQuantity, nil
+}
+
+// UpdateStockTx applies delta to itemID's quantity and enqueues its
+// outbox event using tx, without committing. It is shared by UpdateStock
+// and the gRPC server's idempotency-wrapped UpdateStock RPC (see
+// WithIdempotency), which needs the stock mutation in the same
+// transaction as the idempotency key it claims.
+func UpdateStockTx(tx *sql.Tx, itemID string, delta int32) (int32, error) {
+	var previousQuantity int32
+	if err := tx.QueryRow(`SELECT quantity FROM items WHERE item_id = $1 FOR UPDATE`, itemID).Scan(&previousQuantity); err != nil {
+		return 0, err
+	}
+
+	var newQuantity int32
+	if err := tx.QueryRow(`UPDATE items SET quantity = quantity + $1 WHERE item_id = $2 RETURNING quantity`, delta, itemID).Scan(&newQuantity); err != nil {
+		return 0, err
+	}
+
+	if err := enqueueOutbox(tx, itemID, outboxEventStockUpdated, stockUpdatedOutboxPayload{
+		ItemID:           itemID,
+		PreviousQuantity: previousQuantity,
+		NewQuantity:      newQuantity,
+		Delta:            delta,
+		Reason:           "Manual update",
+	}); err != nil {
+		return 0, err
+	}
+
 	return newQuantity, nil
 }
 
@@ -95,26 +201,36 @@ func (r *InventoryRepo) ReserveStock(orderID string, reserved []db.ReservedItem)
 		return err
 	}
 
+	if err := ReserveStockTx(tx, orderID, reserved); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReserveStockTx is the shared core of ReserveStock; see UpdateStockTx.
+func ReserveStockTx(tx *sql.Tx, orderID string, reserved []db.ReservedItem) error {
 	for _, ri := range reserved {
 		var current int32
 		if err := tx.QueryRow(`SELECT quantity FROM items WHERE item_id = $1 FOR UPDATE`, ri.ItemID).Scan(&current); err != nil {
-			tx.Rollback()
 			return err
 		}
 		if current < ri.Quantity {
-			tx.Rollback()
 			return fmt.Errorf("insufficient stock for item %s: available=%d, requested=%d", ri.ItemID, current, ri.Quantity)
 		}
 		if _, err := tx.Exec(`UPDATE items SET quantity = quantity - $1 WHERE item_id = $2`, ri.Quantity, ri.ItemID); err != nil {
-			tx.Rollback()
 			return err
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return err
-	}
-	return nil
+	return enqueueOutbox(tx, orderID, outboxEventStockReserved, stockReservedOutboxPayload{
+		OrderID: orderID,
+		Items:   toOutboxItems(reserved),
+	})
 }
 
 func (r *InventoryRepo) ReleaseStock(orderID string, reserved []db.ReservedItem) error {
@@ -123,15 +239,60 @@ func (r *InventoryRepo) ReleaseStock(orderID string, reserved []db.ReservedItem)
 		return err
 	}
 
+	if err := ReleaseStockTx(tx, orderID, reserved); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ReleaseStockTx is the shared core of ReleaseStock; see UpdateStockTx.
+func ReleaseStockTx(tx *sql.Tx, orderID string, reserved []db.ReservedItem) error {
 	for _, ri := range reserved {
 		if _, err := tx.Exec(`UPDATE items SET quantity = quantity + $1 WHERE item_id = $2`, ri.Quantity, ri.ItemID); err != nil {
-			tx.Rollback()
 			return err
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	return enqueueOutbox(tx, orderID, outboxEventStockReleased, stockReleasedOutboxPayload{
+		OrderID: orderID,
+		Items:   toOutboxItems(reserved),
+	})
+}
+
+// ExpireReservation releases orderID's reserved stock and enqueues an
+// inventory.reservation_expired outbox event in the same transaction, so
+// the orders service can cancel the order once OutboxDispatcher delivers
+// it. Called by the saga timeout reaper once a reservation's TTL has
+// passed with no order.confirmed/order.paid/order.completed; see
+// ReleaseStock for the explicit-cancellation counterpart.
+func (r *InventoryRepo) ExpireReservation(orderID string, reserved []db.ReservedItem) error {
+	tx, err := r.db.Begin()
+	if err != nil {
 		return err
 	}
-	return nil
+
+	if err := ReleaseStockTx(tx, orderID, reserved); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := enqueueOutbox(tx, orderID, outboxEventReservationExpired, reservationExpiredOutboxPayload{OrderID: orderID}); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func toOutboxItems(reserved []db.ReservedItem) []ReservedItemRef {
+	items := make([]ReservedItemRef, 0, len(reserved))
+	for _, ri := range reserved {
+		items = append(items, ReservedItemRef{ItemID: ri.ItemID, Quantity: ri.Quantity})
+	}
+	return items
 }
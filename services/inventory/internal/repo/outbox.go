@@ -0,0 +1,265 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Outbox event types. These mirror the routing keys used by
+// internal/events so the dispatcher can hand a row's payload straight to
+// the broker without the repo package depending on the events package.
+const (
+	outboxEventItemCreated        = "inventory.created"
+	outboxEventItemDeleted        = "inventory.deleted"
+	outboxEventStockReserved      = "inventory.stock_reserved"
+	outboxEventStockReleased      = "inventory.stock_released"
+	outboxEventStockUpdated       = "inventory.stock_updated"
+	outboxEventReservationExpired = "inventory.reservation_expired"
+)
+
+// OutboxRow is a row claimed from the outbox table by the dispatcher for
+// delivery.
+type OutboxRow struct {
+	ID          int64
+	AggregateID string
+	EventType   string
+	Payload     json.RawMessage
+	Attempts    int32
+}
+
+// itemCreatedOutboxPayload mirrors the payload events.Publisher.PublishItemCreated
+// used to send inline, before CreateItem started enqueuing it instead.
+type itemCreatedOutboxPayload struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Quantity int32  `json:"quantity"`
+}
+
+// itemDeletedOutboxPayload mirrors the payload events.Publisher.PublishItemDeleted
+// used to send inline, before DeleteItem started enqueuing it instead.
+type itemDeletedOutboxPayload struct {
+	ItemID string `json:"item_id"`
+}
+
+// stockReservedOutboxPayload mirrors events.StockReservedPayload's JSON
+// shape so the dispatcher can wrap it in a CloudEvent unchanged.
+type stockReservedOutboxPayload struct {
+	OrderID string            `json:"order_id"`
+	Items   []ReservedItemRef `json:"items"`
+}
+
+type stockReleasedOutboxPayload struct {
+	OrderID string            `json:"order_id"`
+	Items   []ReservedItemRef `json:"items"`
+}
+
+type stockUpdatedOutboxPayload struct {
+	ItemID           string `json:"item_id"`
+	PreviousQuantity int32  `json:"previous_quantity"`
+	NewQuantity      int32  `json:"new_quantity"`
+	Delta            int32  `json:"delta"`
+	Reason           string `json:"reason,omitempty"`
+}
+
+// reservationExpiredOutboxPayload mirrors events.ReservationExpiredPayload's
+// JSON shape, so the orders service can subscribe to inventory.reservation_expired
+// and cancel the order whose hold timed out without a confirming payment.
+type reservationExpiredOutboxPayload struct {
+	OrderID string `json:"order_id"`
+}
+
+// ReservedItemRef mirrors db.ReservedItem's JSON shape for outbox payloads.
+type ReservedItemRef struct {
+	ItemID   string `json:"item_id"`
+	Quantity int32  `json:"quantity"`
+}
+
+// enqueueOutbox inserts an event row into the outbox within tx, so it
+// commits atomically with the stock mutation that produced it.
+func enqueueOutbox(tx *sql.Tx, aggregateID, eventType string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO outbox (aggregate_id, event_type, payload) VALUES ($1, $2, $3)`,
+		aggregateID, eventType, body,
+	); err != nil {
+		return fmt.Errorf("failed to enqueue outbox event: %w", err)
+	}
+	return nil
+}
+
+// Transaction runs fn inside a single transaction, committing if fn
+// returns nil and rolling back otherwise. The outbox dispatcher uses it
+// to hold ClaimOutboxBatchTx's FOR UPDATE SKIP LOCKED lock on a claimed
+// batch for as long as it takes to publish and mark every row in it,
+// instead of the lock being released the instant the claim's own query
+// finishes and leaving the batch unprotected against a second dispatcher
+// replica claiming the same rows.
+func (r *InventoryRepo) Transaction(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClaimOutboxBatch locks up to limit rows that are unpublished and have
+// not yet exhausted maxAttempts, skipping rows a concurrent dispatcher
+// already holds. Rows are ordered by (aggregate_id, id) so a single
+// dispatcher delivers events for the same aggregate in the order they
+// were raised.
+func (r *InventoryRepo) ClaimOutboxBatch(ctx context.Context, limit int, maxAttempts int32) ([]OutboxRow, error) {
+	var batch []OutboxRow
+	err := r.Transaction(ctx, func(tx *sql.Tx) error {
+		var err error
+		batch, err = r.ClaimOutboxBatchTx(ctx, tx, limit, maxAttempts)
+		return err
+	})
+	return batch, err
+}
+
+// ClaimOutboxBatchTx is ClaimOutboxBatch run against an already-open tx
+// (see Transaction), so the lock it takes via FOR UPDATE SKIP LOCKED is
+// held until tx commits rather than released as soon as this query
+// returns.
+func (r *InventoryRepo) ClaimOutboxBatchTx(ctx context.Context, tx *sql.Tx, limit int, maxAttempts int32) ([]OutboxRow, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, aggregate_id, event_type, payload, attempts
+		FROM outbox
+		WHERE published_at IS NULL AND attempts < $1
+		ORDER BY aggregate_id, id
+		FOR UPDATE SKIP LOCKED
+		LIMIT $2`, maxAttempts, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox batch: %w", err)
+	}
+	defer rows.Close()
+
+	var batch []OutboxRow
+	for rows.Next() {
+		var row OutboxRow
+		if err := rows.Scan(&row.ID, &row.AggregateID, &row.EventType, &row.Payload, &row.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox row: %w", err)
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
+
+// MarkOutboxPublished records that row id was delivered successfully.
+func (r *InventoryRepo) MarkOutboxPublished(ctx context.Context, id int64) error {
+	return r.Transaction(ctx, func(tx *sql.Tx) error {
+		return r.MarkOutboxPublishedTx(ctx, tx, id)
+	})
+}
+
+// MarkOutboxPublishedTx is MarkOutboxPublished run against an
+// already-open tx; see ClaimOutboxBatchTx.
+func (r *InventoryRepo) MarkOutboxPublishedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE outbox SET published_at = CURRENT_TIMESTAMP WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox row %d published: %w", id, err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed increments the attempt counter for row id after a
+// failed publish.
+func (r *InventoryRepo) MarkOutboxFailed(ctx context.Context, id int64) error {
+	return r.Transaction(ctx, func(tx *sql.Tx) error {
+		return r.MarkOutboxFailedTx(ctx, tx, id)
+	})
+}
+
+// MarkOutboxFailedTx is MarkOutboxFailed run against an already-open tx;
+// see ClaimOutboxBatchTx.
+func (r *InventoryRepo) MarkOutboxFailedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE outbox SET attempts = attempts + 1 WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox row %d failed: %w", id, err)
+	}
+	return nil
+}
+
+// DeadLetterOutbox moves a row that exhausted its retry budget into
+// outbox_dead_letters for manual inspection and removes it from the
+// active outbox.
+func (r *InventoryRepo) DeadLetterOutbox(ctx context.Context, id int64) error {
+	return r.Transaction(ctx, func(tx *sql.Tx) error {
+		return r.DeadLetterOutboxTx(ctx, tx, id)
+	})
+}
+
+// DeadLetterOutboxTx is DeadLetterOutbox run against an already-open tx
+// (see ClaimOutboxBatchTx) — important here specifically, since a fresh
+// transaction on a separate connection would block on the very row lock
+// the caller is already holding from ClaimOutboxBatchTx, deadlocking the
+// drain instead of completing it.
+func (r *InventoryRepo) DeadLetterOutboxTx(ctx context.Context, tx *sql.Tx, id int64) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO outbox_dead_letters (outbox_id, aggregate_id, event_type, payload, attempts)
+		SELECT id, aggregate_id, event_type, payload, attempts FROM outbox WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to dead-letter outbox row %d: %w", id, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to remove dead-lettered outbox row %d: %w", id, err)
+	}
+
+	return nil
+}
+
+// OldestUnpublishedOutboxAge reports how long the oldest unpublished
+// outbox row has been waiting, for the dispatcher's lag gauge. It
+// returns zero when the outbox is empty.
+func (r *InventoryRepo) OldestUnpublishedOutboxAge(ctx context.Context) (time.Duration, error) {
+	var oldest sql.NullTime
+	err := r.db.QueryRowContext(ctx, `SELECT MIN(created_at) FROM outbox WHERE published_at IS NULL`).Scan(&oldest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read oldest unpublished outbox row: %w", err)
+	}
+	if !oldest.Valid {
+		return 0, nil
+	}
+	return time.Since(oldest.Time), nil
+}
+
+// EventAlreadyProcessed reports whether eventID is already recorded in
+// the inbox, without claiming it. The consumer calls this before running
+// a handler and calls MarkEventProcessed only after the handler
+// succeeds, so a message that fails partway through is retried as a
+// fresh attempt rather than skipped as its own duplicate.
+func (r *InventoryRepo) EventAlreadyProcessed(ctx context.Context, eventID string) (bool, error) {
+	var exists int
+	err := r.db.QueryRowContext(ctx, `SELECT 1 FROM processed_events WHERE event_id = $1`, eventID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check processed event %s: %w", eventID, err)
+	}
+	return true, nil
+}
+
+// MarkEventProcessed records eventID as processed (the inbox half of
+// at-least-once delivery), so a later redelivery of the same event is
+// recognized by EventAlreadyProcessed and skipped instead of re-run.
+func (r *InventoryRepo) MarkEventProcessed(ctx context.Context, eventID string) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO processed_events (event_id) VALUES ($1) ON CONFLICT (event_id) DO NOTHING`, eventID)
+	if err != nil {
+		return fmt.Errorf("failed to record processed event %s: %w", eventID, err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SagaState is one state in the order/inventory reservation saga's
+// lifecycle: Pending -> Reserved -> Committed | Released | Expired | Failed.
+// Released and Expired are both compensating transitions that release
+// the reserved stock; they're kept distinct so a reporting query (or an
+// operator) can tell an explicit order.cancelled apart from a
+// reservation the timeout reaper gave up waiting on.
+type SagaState string
+
+const (
+	SagaPending   SagaState = "pending"
+	SagaReserved  SagaState = "reserved"
+	SagaCommitted SagaState = "committed"
+	SagaReleased  SagaState = "released"
+	SagaExpired   SagaState = "expired"
+	SagaFailed    SagaState = "failed"
+)
+
+// Saga is the persisted state of one order's reservation saga.
+type Saga struct {
+	OrderID       string
+	State         SagaState
+	CorrelationID string
+	Items         []ReservedItemRef
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	ExpiresAt     *time.Time
+}
+
+// CreateSaga inserts a new saga row in SagaPending for orderID. It is a
+// no-op if a saga for orderID already exists, so a redelivered
+// order.created event does not restart the saga.
+func (r *InventoryRepo) CreateSaga(ctx context.Context, orderID, correlationID string, items []ReservedItemRef) error {
+	body, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal saga items for order %s: %w", orderID, err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO sagas (order_id, state, correlation_id, reserved_items)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (order_id) DO NOTHING`,
+		orderID, SagaPending, correlationID, body)
+	if err != nil {
+		return fmt.Errorf("failed to create saga for order %s: %w", orderID, err)
+	}
+	return nil
+}
+
+// TransitionSaga moves orderID's saga from "from" to "to" and appends the
+// transition to saga_transitions, returning ok=false without error if the
+// saga is not currently in "from" (a redelivered event, or a race with
+// the timeout reaper, neither of which should double-apply).
+func (r *InventoryRepo) TransitionSaga(ctx context.Context, orderID string, from, to SagaState, expiresAt *time.Time) (ok bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var correlationID sql.NullString
+	result, err := tx.ExecContext(ctx, `
+		UPDATE sagas SET state = $1, expires_at = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE order_id = $3 AND state = $4`,
+		to, expiresAt, orderID, from)
+	if err != nil {
+		return false, fmt.Errorf("failed to transition saga %s: %w", orderID, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if rows == 0 {
+		return false, tx.Commit()
+	}
+
+	if err = tx.QueryRowContext(ctx, `SELECT correlation_id FROM sagas WHERE order_id = $1`, orderID).Scan(&correlationID); err != nil {
+		return false, fmt.Errorf("failed to read correlation id for saga %s: %w", orderID, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO saga_transitions (order_id, from_state, to_state, correlation_id)
+		VALUES ($1, $2, $3, $4)`,
+		orderID, from, to, correlationID); err != nil {
+		return false, fmt.Errorf("failed to record transition for saga %s: %w", orderID, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetSaga returns the saga for orderID, or nil if none exists.
+func (r *InventoryRepo) GetSaga(ctx context.Context, orderID string) (*Saga, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT order_id, state, correlation_id, reserved_items, created_at, updated_at, expires_at
+		FROM sagas WHERE order_id = $1`, orderID)
+
+	saga, err := scanSaga(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return saga, err
+}
+
+// ExpiredReservedSagas returns sagas still in SagaReserved whose
+// expires_at has passed, for the timeout reaper to compensate.
+func (r *InventoryRepo) ExpiredReservedSagas(ctx context.Context) ([]Saga, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT order_id, state, correlation_id, reserved_items, created_at, updated_at, expires_at
+		FROM sagas WHERE state = $1 AND expires_at IS NOT NULL AND expires_at < CURRENT_TIMESTAMP`, SagaReserved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expired sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var expired []Saga
+	for rows.Next() {
+		saga, err := scanSaga(rows)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, *saga)
+	}
+	return expired, rows.Err()
+}
+
+// sagaScanner is the subset of *sql.Row / *sql.Rows that scanSaga needs.
+type sagaScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSaga(row sagaScanner) (*Saga, error) {
+	var saga Saga
+	var correlationID sql.NullString
+	var items json.RawMessage
+	var expiresAt sql.NullTime
+
+	if err := row.Scan(&saga.OrderID, &saga.State, &correlationID, &items, &saga.CreatedAt, &saga.UpdatedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+
+	saga.CorrelationID = correlationID.String
+	if expiresAt.Valid {
+		saga.ExpiresAt = &expiresAt.Time
+	}
+	if len(items) > 0 {
+		if err := json.Unmarshal(items, &saga.Items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal saga items for order %s: %w", saga.OrderID, err)
+		}
+	}
+	return &saga, nil
+}
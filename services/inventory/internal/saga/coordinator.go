@@ -0,0 +1,202 @@
+// Package saga coordinates the order/inventory reservation saga: an
+// order.created event reserves stock, order.confirmed (or order.paid,
+// order.completed) commits the reservation, and order.cancelled releases
+// it explicitly while a reservation that times out before any of those
+// arrive is released by the timeout reaper instead, distinguished from an
+// explicit cancellation by ending in SagaExpired rather than
+// SagaReleased. Every transition is persisted and audited by
+// repo.InventoryRepo so the saga survives a restart and GetStatus can
+// report it from scratch.
+package saga
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bookstore/inventory/internal/db"
+	"github.com/bookstore/inventory/internal/repo"
+)
+
+// DefaultReservationTimeout is how long a saga may sit in SagaReserved
+// without an order.confirmed before the timeout reaper releases it.
+const DefaultReservationTimeout = 15 * time.Minute
+
+// sagaRepo is the subset of *repo.InventoryRepo's API that Coordinator
+// depends on, so tests can drive the state machine against an in-memory
+// fake instead of a real database.
+type sagaRepo interface {
+	CreateSaga(ctx context.Context, orderID, correlationID string, items []repo.ReservedItemRef) error
+	TransitionSaga(ctx context.Context, orderID string, from, to repo.SagaState, expiresAt *time.Time) (bool, error)
+	GetSaga(ctx context.Context, orderID string) (*repo.Saga, error)
+	ExpiredReservedSagas(ctx context.Context) ([]repo.Saga, error)
+	ReserveStock(orderID string, reserved []db.ReservedItem) error
+	ReleaseStock(orderID string, reserved []db.ReservedItem) error
+	ExpireReservation(orderID string, reserved []db.ReservedItem) error
+}
+
+// Coordinator drives the saga state machine for inventory reservations.
+type Coordinator struct {
+	repo    sagaRepo
+	timeout time.Duration
+}
+
+// NewCoordinator returns a Coordinator that auto-releases a reservation
+// still unconfirmed after timeout.
+func NewCoordinator(repository *repo.InventoryRepo, timeout time.Duration) *Coordinator {
+	if timeout <= 0 {
+		timeout = DefaultReservationTimeout
+	}
+	return &Coordinator{repo: repository, timeout: timeout}
+}
+
+// HandleOrderCreated starts the saga for orderID: it records a Pending
+// saga, reserves stock, then transitions to Reserved with an expiry. A
+// saga already past Pending (a redelivered or retried order.created) is
+// left as it is, without reserving its stock again. On insufficient
+// stock the saga is marked Failed and the error is returned to the
+// caller.
+func (c *Coordinator) HandleOrderCreated(ctx context.Context, orderID, correlationID string, items []db.ReservedItem) error {
+	if err := c.repo.CreateSaga(ctx, orderID, correlationID, toSagaItems(items)); err != nil {
+		return fmt.Errorf("failed to start saga for order %s: %w", orderID, err)
+	}
+
+	saga, err := c.repo.GetSaga(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to look up saga %s: %w", orderID, err)
+	}
+	if saga != nil && saga.State != repo.SagaPending {
+		log.Printf("  Saga %s is already %s; not reserving its stock again", orderID, saga.State)
+		return nil
+	}
+
+	if err := c.repo.ReserveStock(orderID, items); err != nil {
+		if _, failErr := c.repo.TransitionSaga(ctx, orderID, repo.SagaPending, repo.SagaFailed, nil); failErr != nil {
+			log.Printf("  Failed to mark saga %s failed: %v", orderID, failErr)
+		}
+		return fmt.Errorf("failed to reserve stock for order %s: %w", orderID, err)
+	}
+
+	expiresAt := time.Now().Add(c.timeout)
+	ok, err := c.repo.TransitionSaga(ctx, orderID, repo.SagaPending, repo.SagaReserved, &expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark saga %s reserved: %w", orderID, err)
+	}
+	if !ok {
+		log.Printf("  Saga %s was not pending; leaving its reservation as-is", orderID)
+	}
+	return nil
+}
+
+// HandleOrderConfirmed commits a Reserved saga, ending its lifecycle
+// without releasing the reserved stock.
+func (c *Coordinator) HandleOrderConfirmed(ctx context.Context, orderID string) error {
+	ok, err := c.repo.TransitionSaga(ctx, orderID, repo.SagaReserved, repo.SagaCommitted, nil)
+	if err != nil {
+		return fmt.Errorf("failed to commit saga %s: %w", orderID, err)
+	}
+	if !ok {
+		log.Printf("  Saga %s was not reserved; ignoring order.confirmed", orderID)
+	}
+	return nil
+}
+
+// HandleOrderCancelled compensates a Reserved saga by releasing its
+// stock and transitioning it to Released.
+func (c *Coordinator) HandleOrderCancelled(ctx context.Context, orderID string, items []db.ReservedItem) error {
+	saga, err := c.repo.GetSaga(ctx, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to look up saga %s: %w", orderID, err)
+	}
+	if saga == nil || saga.State != repo.SagaReserved {
+		log.Printf("  Saga %s is not reserved; ignoring order.cancelled", orderID)
+		return nil
+	}
+
+	if err := c.repo.ReleaseStock(orderID, items); err != nil {
+		return fmt.Errorf("failed to release stock for order %s: %w", orderID, err)
+	}
+
+	ok, err := c.repo.TransitionSaga(ctx, orderID, repo.SagaReserved, repo.SagaReleased, nil)
+	if err != nil {
+		return fmt.Errorf("failed to mark saga %s released: %w", orderID, err)
+	}
+	if !ok {
+		log.Printf("  Saga %s changed state before it could be marked released", orderID)
+	}
+	return nil
+}
+
+// GetStatus returns the current saga for orderID, or nil if none exists.
+//
+// TODO(saga): this is meant to back a gRPC SagaService.GetStatus for
+// debugging, but no contracts/gen/go/saga package exists yet to
+// generate that service from (see cmd/inventoryd/main.go). Until a
+// saga.proto is added to contracts and generated alongside the other
+// services, GetStatus is reachable only in-process; it is not a
+// resolved gap, just one with nothing in this snapshot to build the
+// surface against.
+func (c *Coordinator) GetStatus(ctx context.Context, orderID string) (*repo.Saga, error) {
+	return c.repo.GetSaga(ctx, orderID)
+}
+
+// RunTimeoutReaper polls for Reserved sagas past their expiry and
+// compensates them by releasing their stock, until ctx is cancelled.
+func (c *Coordinator) RunTimeoutReaper(ctx context.Context, pollInterval time.Duration) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.reapExpired(ctx)
+		}
+	}
+}
+
+func (c *Coordinator) reapExpired(ctx context.Context) {
+	expired, err := c.repo.ExpiredReservedSagas(ctx)
+	if err != nil {
+		log.Printf("  Failed to list expired sagas: %v", err)
+		return
+	}
+
+	for _, saga := range expired {
+		items := fromSagaItems(saga.Items)
+
+		// ExpireReservation releases the stock and enqueues
+		// inventory.reservation_expired in the same transaction, so the
+		// orders service learns about the timeout and can cancel the
+		// order, instead of the order sitting unpaid with no one ever
+		// deciding its fate.
+		if err := c.repo.ExpireReservation(saga.OrderID, items); err != nil {
+			log.Printf("  Failed to auto-release timed-out saga %s: %v", saga.OrderID, err)
+			continue
+		}
+
+		if _, err := c.repo.TransitionSaga(ctx, saga.OrderID, repo.SagaReserved, repo.SagaExpired, nil); err != nil {
+			log.Printf("  Failed to mark timed-out saga %s expired: %v", saga.OrderID, err)
+			continue
+		}
+		log.Printf("Saga %s timed out without confirmation; reservation released", saga.OrderID)
+	}
+}
+
+func toSagaItems(items []db.ReservedItem) []repo.ReservedItemRef {
+	refs := make([]repo.ReservedItemRef, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, repo.ReservedItemRef{ItemID: item.ItemID, Quantity: item.Quantity})
+	}
+	return refs
+}
+
+func fromSagaItems(refs []repo.ReservedItemRef) []db.ReservedItem {
+	items := make([]db.ReservedItem, 0, len(refs))
+	for _, ref := range refs {
+		items = append(items, db.ReservedItem{ItemID: ref.ItemID, Quantity: ref.Quantity})
+	}
+	return items
+}
@@ -0,0 +1,213 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bookstore/inventory/internal/db"
+	"github.com/bookstore/inventory/internal/repo"
+)
+
+// fakeSagaRepo is an in-memory sagaRepo stand-in so the Coordinator's
+// state machine can be exercised without a real database.
+type fakeSagaRepo struct {
+	sagas map[string]*repo.Saga
+
+	reserveErr error
+	releaseErr error
+	expireErr  error
+
+	reserved []string
+	released []string
+	expired  []string
+}
+
+func newFakeSagaRepo() *fakeSagaRepo {
+	return &fakeSagaRepo{sagas: map[string]*repo.Saga{}}
+}
+
+func (f *fakeSagaRepo) CreateSaga(ctx context.Context, orderID, correlationID string, items []repo.ReservedItemRef) error {
+	if _, exists := f.sagas[orderID]; exists {
+		return nil
+	}
+	f.sagas[orderID] = &repo.Saga{
+		OrderID:       orderID,
+		State:         repo.SagaPending,
+		CorrelationID: correlationID,
+		Items:         items,
+	}
+	return nil
+}
+
+func (f *fakeSagaRepo) TransitionSaga(ctx context.Context, orderID string, from, to repo.SagaState, expiresAt *time.Time) (bool, error) {
+	saga, ok := f.sagas[orderID]
+	if !ok || saga.State != from {
+		return false, nil
+	}
+	saga.State = to
+	saga.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (f *fakeSagaRepo) GetSaga(ctx context.Context, orderID string) (*repo.Saga, error) {
+	saga, ok := f.sagas[orderID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *saga
+	return &copied, nil
+}
+
+func (f *fakeSagaRepo) ExpiredReservedSagas(ctx context.Context) ([]repo.Saga, error) {
+	now := time.Now()
+	var expired []repo.Saga
+	for _, saga := range f.sagas {
+		if saga.State == repo.SagaReserved && saga.ExpiresAt != nil && saga.ExpiresAt.Before(now) {
+			expired = append(expired, *saga)
+		}
+	}
+	return expired, nil
+}
+
+func (f *fakeSagaRepo) ReserveStock(orderID string, reserved []db.ReservedItem) error {
+	if f.reserveErr != nil {
+		return f.reserveErr
+	}
+	f.reserved = append(f.reserved, orderID)
+	return nil
+}
+
+func (f *fakeSagaRepo) ReleaseStock(orderID string, reserved []db.ReservedItem) error {
+	if f.releaseErr != nil {
+		return f.releaseErr
+	}
+	f.released = append(f.released, orderID)
+	return nil
+}
+
+func (f *fakeSagaRepo) ExpireReservation(orderID string, reserved []db.ReservedItem) error {
+	if f.expireErr != nil {
+		return f.expireErr
+	}
+	f.expired = append(f.expired, orderID)
+	return nil
+}
+
+func newTestCoordinator(r *fakeSagaRepo) *Coordinator {
+	return &Coordinator{repo: r, timeout: DefaultReservationTimeout}
+}
+
+func TestHandleOrderCreatedReservesAndMarksReserved(t *testing.T) {
+	r := newFakeSagaRepo()
+	c := newTestCoordinator(r)
+	items := []db.ReservedItem{{ItemID: "BOOK-001", Quantity: 2}}
+
+	if err := c.HandleOrderCreated(context.Background(), "ORDER-1", "corr-1", items); err != nil {
+		t.Fatalf("HandleOrderCreated: %v", err)
+	}
+
+	if len(r.reserved) != 1 || r.reserved[0] != "ORDER-1" {
+		t.Fatalf("expected stock reserved for ORDER-1, got %v", r.reserved)
+	}
+
+	saga := r.sagas["ORDER-1"]
+	if saga.State != repo.SagaReserved {
+		t.Fatalf("expected saga state %s, got %s", repo.SagaReserved, saga.State)
+	}
+	if saga.ExpiresAt == nil {
+		t.Fatal("expected saga to have an expiry set")
+	}
+}
+
+func TestHandleOrderCreatedFailsSagaOnInsufficientStock(t *testing.T) {
+	r := newFakeSagaRepo()
+	r.reserveErr = errors.New("insufficient stock")
+	c := newTestCoordinator(r)
+	items := []db.ReservedItem{{ItemID: "BOOK-001", Quantity: 999}}
+
+	err := c.HandleOrderCreated(context.Background(), "ORDER-2", "corr-2", items)
+	if err == nil {
+		t.Fatal("expected an error from HandleOrderCreated")
+	}
+
+	saga := r.sagas["ORDER-2"]
+	if saga.State != repo.SagaFailed {
+		t.Fatalf("expected saga state %s, got %s", repo.SagaFailed, saga.State)
+	}
+}
+
+func TestHandleOrderConfirmedCommitsReservedSaga(t *testing.T) {
+	r := newFakeSagaRepo()
+	c := newTestCoordinator(r)
+	items := []db.ReservedItem{{ItemID: "BOOK-001", Quantity: 1}}
+
+	if err := c.HandleOrderCreated(context.Background(), "ORDER-3", "corr-3", items); err != nil {
+		t.Fatalf("HandleOrderCreated: %v", err)
+	}
+	if err := c.HandleOrderConfirmed(context.Background(), "ORDER-3"); err != nil {
+		t.Fatalf("HandleOrderConfirmed: %v", err)
+	}
+
+	if r.sagas["ORDER-3"].State != repo.SagaCommitted {
+		t.Fatalf("expected saga state %s, got %s", repo.SagaCommitted, r.sagas["ORDER-3"].State)
+	}
+}
+
+func TestHandleOrderCancelledReleasesReservedStock(t *testing.T) {
+	r := newFakeSagaRepo()
+	c := newTestCoordinator(r)
+	items := []db.ReservedItem{{ItemID: "BOOK-001", Quantity: 1}}
+
+	if err := c.HandleOrderCreated(context.Background(), "ORDER-4", "corr-4", items); err != nil {
+		t.Fatalf("HandleOrderCreated: %v", err)
+	}
+	if err := c.HandleOrderCancelled(context.Background(), "ORDER-4", items); err != nil {
+		t.Fatalf("HandleOrderCancelled: %v", err)
+	}
+
+	if len(r.released) != 1 || r.released[0] != "ORDER-4" {
+		t.Fatalf("expected stock released for ORDER-4, got %v", r.released)
+	}
+	if r.sagas["ORDER-4"].State != repo.SagaReleased {
+		t.Fatalf("expected saga state %s, got %s", repo.SagaReleased, r.sagas["ORDER-4"].State)
+	}
+}
+
+func TestHandleOrderCancelledIgnoresNonReservedSaga(t *testing.T) {
+	r := newFakeSagaRepo()
+	c := newTestCoordinator(r)
+
+	// No saga exists for this order at all.
+	if err := c.HandleOrderCancelled(context.Background(), "ORDER-5", nil); err != nil {
+		t.Fatalf("HandleOrderCancelled: %v", err)
+	}
+	if len(r.released) != 0 {
+		t.Fatalf("expected no stock released, got %v", r.released)
+	}
+}
+
+func TestReapExpiredReleasesTimedOutReservations(t *testing.T) {
+	r := newFakeSagaRepo()
+	c := newTestCoordinator(r)
+	items := []db.ReservedItem{{ItemID: "BOOK-001", Quantity: 1}}
+
+	if err := c.HandleOrderCreated(context.Background(), "ORDER-6", "corr-6", items); err != nil {
+		t.Fatalf("HandleOrderCreated: %v", err)
+	}
+
+	// Force the reservation's expiry into the past, as if the timeout
+	// had elapsed.
+	past := time.Now().Add(-time.Minute)
+	r.sagas["ORDER-6"].ExpiresAt = &past
+
+	c.reapExpired(context.Background())
+
+	if len(r.expired) != 1 || r.expired[0] != "ORDER-6" {
+		t.Fatalf("expected ORDER-6's reservation expired, got %v", r.expired)
+	}
+	if r.sagas["ORDER-6"].State != repo.SagaExpired {
+		t.Fatalf("expected saga state %s, got %s", repo.SagaExpired, r.sagas["ORDER-6"].State)
+	}
+}
@@ -0,0 +1,139 @@
+// Package websocket streams events.Event messages to subscribed clients
+// over a WebSocket connection, mirroring the same inventory events
+// consumed from and published to RabbitMQ, so a frontend can get live
+// stock updates without polling gRPC.
+package websocket
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bookstore/inventory/internal/events"
+	wsnet "golang.org/x/net/websocket"
+)
+
+const (
+	// heartbeatInterval is how often the server writes a heartbeat frame
+	// to an idle connection, so a client (or an intermediate proxy) can
+	// tell the connection is still alive between real events.
+	heartbeatInterval = 20 * time.Second
+
+	// readIdleTimeout bounds how long a connection may go without the
+	// client sending anything before the server gives up on it. x/net's
+	// websocket.Conn has no frame-level ping/pong of its own, so this is
+	// enforced via a read deadline against whatever the client sends
+	// rather than a true control-frame heartbeat.
+	readIdleTimeout = 60 * time.Second
+
+	writeTimeout = 5 * time.Second
+)
+
+// heartbeatEvent is written on the heartbeat ticker so idle clients get a
+// frame to detect server liveness with, the same role a WebSocket ping
+// frame would play on a transport that exposes one.
+var heartbeatEvent = events.Event{Topic: "heartbeat"}
+
+// Handler upgrades HTTP requests to WebSocket connections and streams
+// events.Event messages out of a hub for as long as the client stays
+// connected.
+type Handler struct {
+	hub   *events.EventHub
+	token string
+}
+
+// NewHandler returns a Handler that streams events from hub. If token is
+// non-empty, a connecting client must supply it as the "token" query
+// parameter; an empty token disables the check, mirroring
+// requireAdminToken's empty-token-means-open-dev-mode convention in
+// cmd/inventoryd/main.go.
+func NewHandler(hub *events.EventHub, token string) *Handler {
+	return &Handler{hub: hub, token: token}
+}
+
+// ServeHTTP authenticates the connection, parses the subscription filter
+// from the query string, and hands off to the x/net/websocket upgrader.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" && r.URL.Query().Get("token") != h.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("unauthorized"))
+		return
+	}
+
+	filter := filterFromQuery(r.URL.Query())
+	wsnet.Handler(func(conn *wsnet.Conn) {
+		h.serve(conn, filter)
+	}).ServeHTTP(w, r)
+}
+
+// filterFromQuery builds a subscription filter from "topics" (a
+// comma-separated list of routing keys) and "subject" (a single
+// aggregate id, e.g. an order id or item id).
+func filterFromQuery(q url.Values) events.HubFilter {
+	var filter events.HubFilter
+	if topics := q.Get("topics"); topics != "" {
+		filter.Topics = strings.Split(topics, ",")
+	}
+	filter.Subject = q.Get("subject")
+	return filter
+}
+
+// serve subscribes to hub with filter and streams events to conn until
+// the client disconnects, the hub drops this subscriber for being slow,
+// or a write fails.
+func (h *Handler) serve(conn *wsnet.Conn, filter events.HubFilter) {
+	defer conn.Close()
+
+	subscription, unsubscribe := h.hub.Subscribe(filter)
+	defer unsubscribe()
+
+	disconnected := make(chan struct{})
+	go watchForDisconnect(conn, disconnected)
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-disconnected:
+			return
+		case event, ok := <-subscription:
+			if !ok {
+				return
+			}
+			if !send(conn, event) {
+				return
+			}
+		case <-ticker.C:
+			if !send(conn, heartbeatEvent) {
+				return
+			}
+		}
+	}
+}
+
+// watchForDisconnect blocks reading frames from conn purely to notice
+// when the client goes away (close, network drop, or an idle timeout
+// past readIdleTimeout); this service never expects a client to send
+// anything meaningful back.
+func watchForDisconnect(conn *wsnet.Conn, disconnected chan<- struct{}) {
+	defer close(disconnected)
+	var discard string
+	for {
+		conn.SetReadDeadline(time.Now().Add(readIdleTimeout))
+		if err := wsnet.Message.Receive(conn, &discard); err != nil {
+			return
+		}
+	}
+}
+
+func send(conn *wsnet.Conn, event events.Event) bool {
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if err := wsnet.JSON.Send(conn, event); err != nil {
+		log.Printf("Dropping WebSocket client, send failed: %v", err)
+		return false
+	}
+	return true
+}
@@ -1,7 +1,9 @@
 package logger
 
 import (
+	"encoding/json"
 	"log/slog"
+	"net/http"
 	"os"
 )
 
@@ -9,14 +11,34 @@ type Logger struct {
 	*slog.Logger
 }
 
-func New(serviceName string) *Logger {
+// New builds a Logger at logLevel ("debug", "info", "warn", "error";
+// defaults to info) and returns a LevelController that can flip its
+// verbosity at runtime, mirroring the catalog service's
+// pkg/logger.NewAtomicLogger.
+func New(serviceName, logLevel string) (*Logger, *LevelController) {
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(parseLevel(logLevel))
+
 	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: levelVar,
 	})
 
 	logger := slog.New(handler).With("service", serviceName)
 
-	return &Logger{Logger: logger}
+	return &Logger{Logger: logger}, &LevelController{level: levelVar}
+}
+
+func parseLevel(logLevel string) slog.Level {
+	switch logLevel {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
 }
 
 func (l *Logger) Info(msg string, args ...any) {
@@ -39,3 +61,34 @@ func (l *Logger) Fatal(msg string, args ...any) {
 	l.Logger.Error(msg, args...)
 	os.Exit(1)
 }
+
+// LevelController exposes a *slog.LevelVar as an HTTP handler: GET
+// returns the current level as JSON, PUT {"level":"debug"} changes it
+// immediately for every subsequent log call. Mount it behind an
+// admin-token check, same as the catalog service's zap.AtomicLevel
+// endpoint.
+type LevelController struct {
+	level *slog.LevelVar
+}
+
+func (c *LevelController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"level": c.level.Level().String()})
+	case http.MethodPut:
+		var body struct {
+			Level string `json:"level"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("invalid request body"))
+			return
+		}
+		c.level.Set(parseLevel(body.Level))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"level": c.level.Level().String()})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,98 @@
+package observability
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// metricsT holds every Prometheus collector this package registers,
+// following the same package-level-singleton pattern as the outbox
+// metrics in internal/events.
+type metricsT struct {
+	grpcRequests *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+
+	dbOpenConnections *prometheus.GaugeVec
+	dbInUse           *prometheus.GaugeVec
+	dbIdle            *prometheus.GaugeVec
+	dbWaitCount       *prometheus.GaugeVec
+}
+
+var metrics = newMetrics()
+
+func newMetrics() *metricsT {
+	m := &metricsT{
+		grpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_requests_total",
+			Help: "Total unary gRPC requests handled, labeled by method and status code.",
+		}, []string{"method", "code"}),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_request_duration_seconds",
+			Help:    "Unary gRPC request duration in seconds, labeled by method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method"}),
+		dbOpenConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "db_pool_open_connections",
+			Help: "Number of established connections, both in use and idle, per pool member.",
+		}, []string{"pool"}),
+		dbInUse: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "db_pool_in_use_connections",
+			Help: "Number of connections currently in use, per pool member.",
+		}, []string{"pool"}),
+		dbIdle: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "db_pool_idle_connections",
+			Help: "Number of idle connections, per pool member.",
+		}, []string{"pool"}),
+		dbWaitCount: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "db_pool_wait_count_total",
+			Help: "Total number of connections waited for, per pool member.",
+		}, []string{"pool"}),
+	}
+	prometheus.MustRegister(
+		m.grpcRequests, m.grpcDuration,
+		m.dbOpenConnections, m.dbInUse, m.dbIdle, m.dbWaitCount,
+	)
+	return m
+}
+
+// UnaryServerInterceptor records request count and latency for every
+// unary RPC, so /metrics reflects gRPC traffic alongside tracing.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		metrics.grpcDuration.WithLabelValues(info.FullMethod).Observe(time.Since(start).Seconds())
+		metrics.grpcRequests.WithLabelValues(info.FullMethod, status.Code(err).String()).Inc()
+		return resp, err
+	}
+}
+
+// RunDBStatsCollector polls sqlDB.Stats() under label pool every interval
+// until ctx is cancelled, exporting the gauges /metrics serves.
+func RunDBStatsCollector(ctx context.Context, pool string, sqlDB *sql.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	collect := func() {
+		stats := sqlDB.Stats()
+		metrics.dbOpenConnections.WithLabelValues(pool).Set(float64(stats.OpenConnections))
+		metrics.dbInUse.WithLabelValues(pool).Set(float64(stats.InUse))
+		metrics.dbIdle.WithLabelValues(pool).Set(float64(stats.Idle))
+		metrics.dbWaitCount.WithLabelValues(pool).Set(float64(stats.WaitCount))
+	}
+
+	collect()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			collect()
+		}
+	}
+}